@@ -0,0 +1,543 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"text/template"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/match"
+	"echoshell/session"
+	"echoshell/state"
+	"echoshell/tmux"
+)
+
+var version = "dev"
+
+// cliTarget is the tmux host subcommands operate on.
+var cliTarget = config.DefaultTarget
+
+var tmuxClient = tmux.ForTarget(cliTarget)
+
+const cliUsage = `usage: echoshell [query...] [--create]
+       echoshell <command> [args]
+
+commands:
+  ls [--json|--format T]   list sessions with repo/workspace
+  attach <query...>        attach the single session matching query
+  new <repo> <template>    create a session from a template, print its name
+  kill <session>           destroy a session
+  restart <session>        respawn a session's panes and rerun its template
+  save                     snapshot all sessions to the state dir
+  restore                  recreate the saved sessions that are not running
+  repos [--json|--format T]
+                           list discovered repos
+  targets                  list remembered targets
+  preview <session>        print the current pane contents of a session
+  completion bash|zsh|fish print a shell completion script
+  version                  print version
+
+Without a command, args are quick-attach search tokens.
+`
+
+// jsonSchemaVersion is bumped whenever a field of the JSON output is renamed,
+// removed or changes meaning. Adding fields does not bump it.
+const jsonSchemaVersion = 1
+
+type jsonSession struct {
+	Name        string `json:"name"`
+	Workspace   string `json:"workspace"`
+	Repo        string `json:"repo"`
+	RepoPath    string `json:"repo_path"`
+	Workdir     string `json:"workdir"`
+	Command     string `json:"command"`
+	Attached    bool   `json:"attached"`
+	Windows     int    `json:"windows"`
+	Activity    int64  `json:"activity"`
+	Attribution string `json:"attribution"`
+	Template    string `json:"template"`
+	TemplateCmd string `json:"template_command"`
+	ResumeCmd   string `json:"resume_command"`
+	CreatedBy   string `json:"created_by"`
+	CreatedOn   string `json:"created_on"`
+	Host        string `json:"host"`
+}
+
+type jsonRepo struct {
+	Workspace string        `json:"workspace"`
+	Repo      string        `json:"repo"`
+	Name      string        `json:"name"`
+	Path      string        `json:"path"`
+	Host      string        `json:"host"`
+	Sessions  []jsonSession `json:"sessions"`
+}
+
+type jsonSessionList struct {
+	Version  int           `json:"version"`
+	Host     string        `json:"host"`
+	Sessions []jsonSession `json:"sessions"`
+}
+
+type jsonRepoList struct {
+	Version int        `json:"version"`
+	Host    string     `json:"host"`
+	Repos   []jsonRepo `json:"repos"`
+}
+
+func runSubcommand(args []string, out io.Writer) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	rest := args[1:]
+	switch args[0] {
+	case "ls":
+		return true, cliList(rest, out)
+	case "attach":
+		return true, cliAttach(rest)
+	case "new":
+		return true, cliNew(rest, out)
+	case "kill":
+		return true, cliKill(rest, out)
+	case "restart":
+		return true, cliRestart(rest, out)
+	case "save":
+		return true, cliSave(rest, out)
+	case "restore":
+		return true, cliRestore(rest, out)
+	case "repos":
+		return true, cliRepos(rest, out)
+	case "targets":
+		return true, cliTargets(rest, out)
+	case "preview":
+		return true, cliPreview(rest, out)
+	case "completion":
+		return true, cliCompletion(rest, out)
+	case "__complete":
+		return true, cliComplete(rest, out)
+	case "version":
+		fmt.Fprintln(out, versionString())
+		return true, nil
+	case "help", "-h", "--help":
+		fmt.Fprint(out, cliUsage)
+		return true, nil
+	}
+	return false, nil
+}
+
+// groupedSessions lists every session, including the one the command runs
+// in; hiding it is a picker rule.
+func groupedSessions() ([]discovery.WorkspaceGroup, error) {
+	return discovery.GroupAllSessions(tmuxClient, cliTarget)
+}
+
+func findQuickCandidates(tokens []string) ([]match.Candidate, error) {
+	groups, err := groupedSessions()
+	if err != nil {
+		return nil, err
+	}
+	return match.Candidates(groups, tokens), nil
+}
+
+func requireTmux() error {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return errors.New("tmux is required")
+	}
+	return nil
+}
+
+func newCLIFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("echoshell "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func cliList(args []string, out io.Writer) error {
+	fs := newCLIFlags("ls")
+	asJSON := fs.Bool("json", false, "print JSON")
+	format := fs.String("format", "", "Go template applied to each session")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*asJSON && *format != "") {
+		return errors.New("usage: echoshell ls [--json|--format T]")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	groups, err := groupedSessions()
+	if err != nil {
+		return err
+	}
+	host := sessionHost()
+	rows := listSessions(groups, host)
+	if *asJSON {
+		return writeJSON(out, jsonSessionList{Version: jsonSchemaVersion, Host: host, Sessions: rows})
+	}
+	if *format != "" {
+		items := make([]any, 0, len(rows))
+		for _, r := range rows {
+			items = append(items, r)
+		}
+		return writeFormatted(out, *format, items)
+	}
+	for _, r := range rows {
+		attached := "-"
+		if r.Attached {
+			attached = "attached"
+		}
+		fmt.Fprintf(out, "%s\t%s/%s\t%d\t%s\n", r.Name, r.Workspace, r.Repo, r.Windows, attached)
+	}
+	return nil
+}
+
+func listSessions(groups []discovery.WorkspaceGroup, host string) []jsonSession {
+	rows := []jsonSession{}
+	for _, g := range groups {
+		rows = append(rows, repoSessions(g, host)...)
+	}
+	return rows
+}
+
+func repoSessions(g discovery.WorkspaceGroup, host string) []jsonSession {
+	rows := make([]jsonSession, 0, len(g.Sessions))
+	for _, s := range g.Sessions {
+		rows = append(rows, jsonSession{
+			Name:        s.Name,
+			Workspace:   discovery.WorkspaceName(g),
+			Repo:        g.Repo,
+			RepoPath:    g.Path,
+			Workdir:     s.Workdir,
+			Command:     s.Command,
+			Attached:    s.Attached,
+			Windows:     s.Windows,
+			Activity:    s.Activity,
+			Attribution: s.Attribution,
+			Template:    s.Meta.Template,
+			TemplateCmd: s.Meta.Command,
+			ResumeCmd:   s.Meta.Resume,
+			CreatedBy:   s.Meta.Creator,
+			CreatedOn:   s.Meta.Host,
+			Host:        host,
+		})
+	}
+	return rows
+}
+
+func listRepos(groups []discovery.WorkspaceGroup, host string) []jsonRepo {
+	rows := make([]jsonRepo, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, jsonRepo{
+			Workspace: discovery.WorkspaceName(g),
+			Repo:      g.Repo,
+			Name:      g.Name,
+			Path:      g.Path,
+			Host:      host,
+			Sessions:  repoSessions(g, host),
+		})
+	}
+	return rows
+}
+
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeFormatted(out io.Writer, format string, items []any) error {
+	tpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid --format: %w", err)
+	}
+	for _, it := range items {
+		if err := tpl.Execute(out, it); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func sessionHost() string {
+	if !config.IsLocalTarget(cliTarget) {
+		return cliTarget
+	}
+	if h, err := os.Hostname(); err == nil && strings.TrimSpace(h) != "" {
+		return strings.TrimSpace(h)
+	}
+	return "local"
+}
+
+func cliAttach(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: echoshell attach <query...>")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	matches, err := findQuickCandidates(args)
+	if err != nil {
+		return err
+	}
+	name, err := pickAttachCandidate(args, matches)
+	if err != nil {
+		return err
+	}
+	return tmux.AttachNow(name)
+}
+
+func pickAttachCandidate(query []string, matches []match.Candidate) (string, error) {
+	q := strings.Join(query, " ")
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no session matches %q", q)
+	}
+	for _, c := range matches {
+		if c.Session.Name == q {
+			return c.Session.Name, nil
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0].Session.Name, nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, c := range matches {
+		names = append(names, c.Session.Name)
+	}
+	return "", fmt.Errorf("%q is ambiguous: %s", q, strings.Join(names, ", "))
+}
+
+func cliNew(args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: echoshell new <repo> <template>")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	groups, err := discovery.RepoGroupsCached(cliTarget)
+	if err != nil {
+		return err
+	}
+	g, err := exactRepo(groups, args[0])
+	if err != nil {
+		return err
+	}
+	t, err := exactTemplate(g.Config.Resolve(config.DefaultTemplates()), args[1])
+	if err != nil {
+		return err
+	}
+	name, err := session.Create(tmuxClient, g.Path, g.Repo, t)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, name)
+	return nil
+}
+
+// exactRepo finds the repo named repo, or workspace/repo when two
+// workspaces share the name. Scripts get an error instead of a fuzzy guess.
+func exactRepo(groups []discovery.WorkspaceGroup, repo string) (discovery.WorkspaceGroup, error) {
+	found := []discovery.WorkspaceGroup{}
+	for _, g := range groups {
+		if g.Path == "/" {
+			continue
+		}
+		if g.Name == repo || g.Repo == repo {
+			found = append(found, g)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return discovery.WorkspaceGroup{}, fmt.Errorf("no repo named %q", repo)
+	case 1:
+		return found[0], nil
+	}
+	names := make([]string, len(found))
+	for i, g := range found {
+		names[i] = g.Name
+	}
+	return discovery.WorkspaceGroup{}, fmt.Errorf("repo %q is ambiguous, use one of: %s", repo, strings.Join(names, ", "))
+}
+
+// exactTemplate finds the template with the given name.
+func exactTemplate(templates []config.Template, name string) (config.Template, error) {
+	names := make([]string, 0, len(templates))
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+		names = append(names, t.Name)
+	}
+	return config.Template{}, fmt.Errorf("no template named %q (have %s)", name, strings.Join(names, ", "))
+}
+
+func cliKill(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: echoshell kill <session>")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	if err := session.Kill(tmuxClient, cliTarget, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Destroyed "+args[0])
+	return nil
+}
+
+func cliRestart(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: echoshell restart <session>")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	groups, err := groupedSessions()
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, s := range g.Sessions {
+			if s.Name != args[0] {
+				continue
+			}
+			t, ok := session.SessionTemplate(g.Repo, s, g.Config.Resolve(config.DefaultTemplates()))
+			if !ok {
+				return fmt.Errorf("don't know which template started %s", s.Name)
+			}
+			if err := session.Restart(tmuxClient, s.Name, t); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Restarted "+s.Name)
+			return nil
+		}
+	}
+	return fmt.Errorf("no session named %q", args[0])
+}
+
+func cliSave(args []string, out io.Writer) error {
+	if len(args) != 0 {
+		return errors.New("usage: echoshell save")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	groups, err := groupedSessions()
+	if err != nil {
+		return err
+	}
+	n, path, err := session.Save(tmuxClient, groups)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Saved %d sessions to %s\n", n, path)
+	return nil
+}
+
+func cliRestore(args []string, out io.Writer) error {
+	if len(args) != 0 {
+		return errors.New("usage: echoshell restore")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	snap, err := state.LoadSnapshot()
+	if err != nil {
+		return err
+	}
+	restored, skipped, err := session.Restore(tmuxClient, snap, config.DefaultTemplates())
+	for _, name := range restored {
+		fmt.Fprintln(out, "Restored "+name)
+	}
+	for _, name := range skipped {
+		fmt.Fprintln(out, "Skipped "+name+" (already running)")
+	}
+	return err
+}
+
+func cliRepos(args []string, out io.Writer) error {
+	fs := newCLIFlags("repos")
+	asJSON := fs.Bool("json", false, "print JSON")
+	format := fs.String("format", "", "Go template applied to each repo")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*asJSON && *format != "") {
+		return errors.New("usage: echoshell repos [--json|--format T]")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	groups, err := groupedSessions()
+	if err != nil {
+		return err
+	}
+	host := sessionHost()
+	rows := listRepos(groups, host)
+	if *asJSON {
+		return writeJSON(out, jsonRepoList{Version: jsonSchemaVersion, Host: host, Repos: rows})
+	}
+	if *format != "" {
+		items := make([]any, 0, len(rows))
+		for _, r := range rows {
+			items = append(items, r)
+		}
+		return writeFormatted(out, *format, items)
+	}
+	for _, r := range rows {
+		fmt.Fprintf(out, "%s\t%s\t%d\n", r.Name, r.Path, len(r.Sessions))
+	}
+	return nil
+}
+
+func cliTargets(args []string, out io.Writer) error {
+	if len(args) != 0 {
+		return errors.New("usage: echoshell targets")
+	}
+	targets, err := state.Targets()
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		fmt.Fprintln(out, t)
+	}
+	return nil
+}
+
+func cliPreview(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: echoshell preview <session>")
+	}
+	if err := requireTmux(); err != nil {
+		return err
+	}
+	text, err := session.Capture(tmuxClient, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, text)
+	return nil
+}
+
+func versionString() string {
+	if version != "dev" {
+		return "echoshell " + version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "echoshell " + version
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return "echoshell " + version + " (" + s.Value[:12] + ")"
+		}
+	}
+	return "echoshell " + version
+}
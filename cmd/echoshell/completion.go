@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/match"
+	"echoshell/session"
+)
+
+var cliCommands = []string{"ls", "attach", "new", "kill", "restart", "save", "restore", "repos", "targets", "preview", "version", "completion"}
+
+const bashCompletion = `# bash completion for echoshell
+_echoshell() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local IFS=$'\n'
+  COMPREPLY=($(echoshell __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" "$cur" 2>/dev/null))
+}
+complete -F _echoshell echoshell
+`
+
+const zshCompletion = `#compdef echoshell
+# zsh completion for echoshell
+_echoshell() {
+  local -a candidates
+  candidates=("${(@f)$(echoshell __complete "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)}")
+  candidates=(${candidates:#})
+  compadd -a candidates
+}
+compdef _echoshell echoshell
+`
+
+const fishCompletion = `# fish completion for echoshell
+function __echoshell_complete
+    set -l tokens (commandline -opc)
+    echoshell __complete $tokens[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c echoshell -f -a '(__echoshell_complete)'
+`
+
+func cliCompletion(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: echoshell completion bash|zsh|fish")
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Fprint(out, bashCompletion)
+	case "zsh":
+		fmt.Fprint(out, zshCompletion)
+	case "fish":
+		fmt.Fprint(out, fishCompletion)
+	default:
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", args[0])
+	}
+	return nil
+}
+
+// cliComplete is the hidden backend of the generated completion scripts. Its
+// args are the words typed so far followed by the word being completed.
+func cliComplete(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	words := args[:len(args)-1]
+	cur := args[len(args)-1]
+
+	groups, err := groupedSessions()
+	if err != nil {
+		groups, _ = discovery.RepoGroupsCached(cliTarget)
+	}
+	for _, c := range completeWords(words, cur, groups, config.DefaultTemplates()) {
+		fmt.Fprintln(out, c)
+	}
+	return nil
+}
+
+func completeWords(words []string, cur string, groups []discovery.WorkspaceGroup, templates []config.Template) []string {
+	var candidates []string
+	if len(words) == 0 {
+		candidates = append(candidates, cliCommands...)
+		candidates = append(candidates, completionRepos(groups)...)
+		candidates = append(candidates, completionSessions(groups)...)
+		return filterCompletions(candidates, cur)
+	}
+
+	rest := words[1:]
+	switch words[0] {
+	case "attach":
+		if len(rest) == 0 {
+			candidates = append(completionRepos(groups), completionSessions(groups)...)
+		} else {
+			candidates = completionRepoSessions(rest[0], groups)
+		}
+	case "new":
+		switch len(rest) {
+		case 0:
+			candidates = completionRepos(groups)
+		case 1:
+			candidates = completionTemplates(repoTemplates(rest[0], groups, templates))
+		}
+	case "kill", "restart", "preview":
+		if len(rest) == 0 {
+			candidates = completionSessions(groups)
+		}
+	case "ls", "repos":
+		candidates = []string{"--json", "--format"}
+	case "completion":
+		if len(rest) == 0 {
+			candidates = []string{"bash", "zsh", "fish"}
+		}
+	case "targets", "version", "save", "restore":
+	default:
+		tokens, _ := parseQuickArgs(words)
+		if len(tokens) > 0 {
+			candidates = completionRepoSessions(tokens[0], groups)
+			candidates = append(candidates, completionTemplates(repoTemplates(tokens[0], groups, templates))...)
+		}
+		candidates = append(candidates, "--create")
+	}
+	return filterCompletions(candidates, cur)
+}
+
+// repoTemplates adds the templates from repo's .echoshell.toml.
+func repoTemplates(repo string, groups []discovery.WorkspaceGroup, templates []config.Template) []config.Template {
+	for _, g := range groups {
+		if g.Repo == repo {
+			return g.Config.Resolve(templates)
+		}
+	}
+	return templates
+}
+
+func completionRepos(groups []discovery.WorkspaceGroup) []string {
+	out := make([]string, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g.Repo)
+	}
+	return out
+}
+
+func completionSessions(groups []discovery.WorkspaceGroup) []string {
+	out := []string{}
+	for _, g := range groups {
+		for _, s := range g.Sessions {
+			out = append(out, s.Name)
+		}
+	}
+	return out
+}
+
+// completionRepoSessions mirrors match.Score: the first arg picks the
+// repo, later args match the session name without its repo prefix.
+func completionRepoSessions(repoQuery string, groups []discovery.WorkspaceGroup) []string {
+	out := []string{}
+	for _, g := range groups {
+		hay := match.Normalize(strings.Join([]string{g.Repo, g.Name, g.Workspace}, " "))
+		if _, ok := match.ScoreHay(repoQuery, hay, true); !ok {
+			continue
+		}
+		for _, s := range g.Sessions {
+			out = append(out, session.TrimRepoPrefix(g.Repo, s.Name))
+		}
+	}
+	return out
+}
+
+func completionTemplates(templates []config.Template) []string {
+	out := make([]string, 0, len(templates))
+	for _, t := range templates {
+		out = append(out, t.Name)
+	}
+	return out
+}
+
+func filterCompletions(candidates []string, cur string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, c := range candidates {
+		if c == "" || seen[c] || !strings.HasPrefix(c, cur) {
+			continue
+		}
+		seen[c] = true
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"echoshell/config"
+	"echoshell/tmux"
+	"echoshell/tui"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if handled, err := runSubcommand(os.Args[1:], os.Stdout); handled {
+		return err
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return errors.New("tmux is required")
+	}
+	// Check the keymap and theme here so a bad config file is reported
+	// before the picker moves into a new tmux session.
+	if _, err := config.LoadKeymap(); err != nil {
+		return err
+	}
+	if _, err := config.LoadTheme(); err != nil {
+		return err
+	}
+	if started, err := bootstrapIntoTmuxIfNeeded(); started {
+		return err
+	}
+	tokens, create := parseQuickArgs(os.Args[1:])
+	return tui.Run(tui.Options{Tokens: tokens, Create: create})
+}
+
+func bootstrapIntoTmuxIfNeeded() (bool, error) {
+	if strings.TrimSpace(os.Getenv("TMUX")) != "" {
+		return false, nil
+	}
+	if strings.TrimSpace(os.Getenv("ECHOSHELL_IN_TMUX")) == "1" {
+		return false, nil
+	}
+	if strings.TrimSpace(os.Getenv("ECHOSHELL_AUTO_TMUX")) == "0" {
+		return false, nil
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return false, nil
+	}
+
+	cmdline := "ECHOSHELL_IN_TMUX=1 " + tmux.ShellQuote(os.Args[0])
+	for _, a := range os.Args[1:] {
+		cmdline += " " + tmux.ShellQuote(a)
+	}
+
+	sessionName := "echoshell"
+	if err := exec.Command("tmux", "has-session", "-t", sessionName).Run(); err == nil {
+		sessionName = fmt.Sprintf("echoshell-%d", os.Getpid())
+	}
+
+	cmd := exec.Command("tmux", "new-session", "-s", sessionName, cmdline)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return true, cmd.Run()
+}
+
+func parseQuickArgs(args []string) ([]string, bool) {
+	tokens := make([]string, 0, len(args))
+	create := false
+	for _, a := range args {
+		if a == "--create" {
+			create = true
+			continue
+		}
+		tokens = append(tokens, a)
+	}
+	return tokens, create
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/match"
+	"echoshell/tmux"
+	"echoshell/tmux/tmuxtest"
+)
+
+func TestParseQuickArgsStripsCreateFlag(t *testing.T) {
+	tokens, create := parseQuickArgs([]string{"app", "--create", "claude"})
+	if !create {
+		t.Fatalf("expected --create to be detected")
+	}
+	if strings.Join(tokens, " ") != "app claude" {
+		t.Fatalf("unexpected tokens: %#v", tokens)
+	}
+}
+
+func TestPickAttachCandidatePrefersExactName(t *testing.T) {
+	matches := []match.Candidate{
+		{Session: discovery.SessionInfo{Name: "app-shell-10"}},
+		{Session: discovery.SessionInfo{Name: "app-shell-1"}},
+	}
+	name, err := pickAttachCandidate([]string{"app-shell-1"}, matches)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "app-shell-1" {
+		t.Fatalf("expected exact match app-shell-1, got %q", name)
+	}
+	if _, err := pickAttachCandidate([]string{"app"}, matches); err == nil {
+		t.Fatalf("expected ambiguous query to fail")
+	}
+}
+
+func TestListSessionsFlattensGroups(t *testing.T) {
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "", Repo: "root", Sessions: []discovery.SessionInfo{{Name: "misc"}}},
+		{Workspace: "git", Repo: "app", Path: "/root/git/app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1", Attached: true, Windows: 2, Command: "bash", Activity: 42}}},
+	}
+	rows := listSessions(groups, "box")
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].Workspace != "root" {
+		t.Fatalf("expected empty workspace to map to root, got %q", rows[0].Workspace)
+	}
+	r := rows[1]
+	if r.Repo != "app" || r.RepoPath != "/root/git/app" || !r.Attached || r.Windows != 2 || r.Command != "bash" || r.Activity != 42 || r.Host != "box" {
+		t.Fatalf("unexpected row: %#v", r)
+	}
+}
+
+func TestJSONSessionListSchema(t *testing.T) {
+	rows := listSessions([]discovery.WorkspaceGroup{{Workspace: "git", Repo: "app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}}}}, "box")
+	var b strings.Builder
+	if err := writeJSON(&b, jsonSessionList{Version: jsonSchemaVersion, Host: "box", Sessions: rows}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{`"version": 1`, `"host": "box"`, `"name": "app-shell-1"`, `"repo_path"`, `"activity"`, `"command"`} {
+		if !strings.Contains(b.String(), key) {
+			t.Fatalf("expected %s in JSON output:\n%s", key, b.String())
+		}
+	}
+}
+
+func TestWriteFormattedAppliesTemplatePerItem(t *testing.T) {
+	rows := []any{jsonSession{Name: "a", Repo: "x"}, jsonSession{Name: "b", Repo: "y"}}
+	var b strings.Builder
+	if err := writeFormatted(&b, "{{.Repo}}:{{.Name}}", rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.String() != "x:a\ny:b\n" {
+		t.Fatalf("unexpected output: %q", b.String())
+	}
+	if err := writeFormatted(&b, "{{.Name", rows); err == nil {
+		t.Fatalf("expected invalid template to fail")
+	}
+}
+
+func TestCompleteWordsQuickAttachUsesRepoThenSession(t *testing.T) {
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "git", Repo: "app", Name: "git/app", Sessions: []discovery.SessionInfo{{Name: "app-claude-1"}, {Name: "app-lazygit-1"}}},
+		{Workspace: "git", Repo: "tools", Name: "git/tools", Sessions: []discovery.SessionInfo{{Name: "tools-lazygit-1"}}},
+	}
+
+	got := completeWords([]string{"app"}, "la", groups, config.DefaultTemplates())
+	if strings.Join(got, " ") != "lazygit lazygit-1" {
+		t.Fatalf("unexpected completions: %#v", got)
+	}
+
+	got = completeWords(nil, "to", groups, config.DefaultTemplates())
+	if strings.Join(got, " ") != "tools tools-lazygit-1" {
+		t.Fatalf("unexpected first-word completions: %#v", got)
+	}
+
+	got = completeWords([]string{"new", "app"}, "cl", groups, config.DefaultTemplates())
+	if strings.Join(got, " ") != "claude claude-full" {
+		t.Fatalf("unexpected template completions: %#v", got)
+	}
+}
+
+func TestListRejectsPositionalArgs(t *testing.T) {
+	var out strings.Builder
+	if err := cliList([]string{"foo"}, &out); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Fatalf("expected a usage error, got %v", err)
+	}
+}
+
+func TestListingRejectsJSONWithFormat(t *testing.T) {
+	for name, cmd := range map[string]func([]string, io.Writer) error{"ls": cliList, "repos": cliRepos} {
+		var out strings.Builder
+		if err := cmd([]string{"--json", "--format", "{{.Name}}"}, &out); err == nil || !strings.Contains(err.Error(), "usage") {
+			t.Fatalf("%s: expected a usage error, got %v", name, err)
+		}
+	}
+}
+
+func TestScriptingCommandsSeeTheCallersOwnSession(t *testing.T) {
+	if err := requireTmux(); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_PANE", "%99")
+	f := tmuxtest.NewFake(tmuxtest.Session{Name: "app-shell-1", Path: "/git/app"})
+	f.Current = "app-shell-1"
+	defer func(b tmux.Backend) { tmuxClient = b }(tmuxClient)
+	tmuxClient = f
+	discovery.SetCache(cliTarget, []discovery.WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app"},
+	})
+	t.Cleanup(func() { discovery.SetCache(cliTarget, nil) })
+
+	var out strings.Builder
+	if err := cliList([]string{"--format", "{{.Name}}"}, &out); err != nil || strings.TrimSpace(out.String()) != "app-shell-1" {
+		t.Fatalf("expected ls to list the current session, got %q %v", out.String(), err)
+	}
+	out.Reset()
+	if err := cliRestart([]string{"app-shell-1"}, &out); err != nil {
+		t.Fatalf("expected restart to find the current session, got %v", err)
+	}
+}
+
+func TestNewResolvesRepoAndTemplateExactly(t *testing.T) {
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app"},
+		{Workspace: "git", Repo: "api", Name: "git/api", Path: "/git/api"},
+		{Workspace: "work", Repo: "api", Name: "work/api", Path: "/work/api"},
+	}
+	if g, err := exactRepo(groups, "app"); err != nil || g.Path != "/git/app" {
+		t.Fatalf("expected app, got %#v %v", g, err)
+	}
+	if _, err := exactRepo(groups, "ap"); err == nil {
+		t.Fatalf("expected a prefix not to match")
+	}
+	if _, err := exactRepo(groups, "root"); err == nil {
+		t.Fatalf("expected root not to be a repo")
+	}
+	if _, err := exactRepo(groups, "api"); err == nil || !strings.Contains(err.Error(), "work/api") {
+		t.Fatalf("expected an ambiguity error, got %v", err)
+	}
+	if g, err := exactRepo(groups, "work/api"); err != nil || g.Path != "/work/api" {
+		t.Fatalf("expected workspace/repo to pick one, got %#v %v", g, err)
+	}
+
+	if tpl, err := exactTemplate(config.DefaultTemplates(), "claude"); err != nil || tpl.Command != "claude" {
+		t.Fatalf("expected claude, got %#v %v", tpl, err)
+	}
+	if _, err := exactTemplate(config.DefaultTemplates(), "sh"); err == nil {
+		t.Fatalf("expected a partial template name not to match")
+	}
+	if err := cliNew([]string{"app", "claude", "extra"}, &strings.Builder{}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Fatalf("expected extra words to be a usage error, got %v", err)
+	}
+}
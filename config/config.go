@@ -0,0 +1,158 @@
+// Package config holds echoshell's session templates, target names and the
+// environment knobs that change its behavior.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultTarget is the target name for tmux on this machine.
+const DefaultTarget = "local"
+
+// Template is a command a new session can be started with. Resume, when
+// set, continues the previous conversation and is used instead of Command
+// when a session is restarted or restored.
+type Template struct {
+	Label   string `toml:"label"`
+	Name    string `toml:"name"`
+	Command string `toml:"command"`
+	Resume  string `toml:"resume"`
+	// Env is passed to the session's environment, never typed into it.
+	Env Env `toml:"env"`
+	// Pre are typed into the first pane before the command, for example
+	// "nix develop".
+	Pre []string `toml:"pre"`
+	// Exec runs the pre-commands and command as the first pane's process
+	// instead of typing them into an interactive shell, which avoids racing
+	// shell startup. The pane drops into a shell when the command exits.
+	Exec bool `toml:"exec"`
+}
+
+// Startup lists what is typed into a new session's first pane: the
+// pre-commands, then Resume when resuming and set, else Command.
+func (t Template) Startup(resume bool) []string {
+	lines := []string{}
+	for _, p := range t.Pre {
+		if strings.TrimSpace(p) != "" {
+			lines = append(lines, p)
+		}
+	}
+	command := t.Command
+	if resume && t.Resume != "" {
+		command = t.Resume
+	}
+	if strings.TrimSpace(command) != "" {
+		lines = append(lines, command)
+	}
+	return lines
+}
+
+func DefaultTemplates() []Template {
+	return []Template{
+		{Label: "Shell (default)", Name: "shell", Command: ""},
+		{Label: "Claude (claude)", Name: "claude", Command: "claude", Resume: "claude --continue"},
+		{Label: "Claude FULL (sandbox off)", Name: "claude-full", Command: "claude --dangerously-skip-permissions", Resume: "claude --dangerously-skip-permissions --continue", Env: Env{"IS_SANDBOX": Literal("1")}},
+		{Label: "OpenCode (opencode)", Name: "opencode", Command: "opencode", Resume: "opencode --continue"},
+		{Label: "Lazygit (lazygit)", Name: "lazygit", Command: "lazygit"},
+		{Label: "Neovim (nvim .)", Name: "neovim", Command: "nvim ."},
+	}
+}
+
+// Dir is echoshell's directory under the user config dir.
+func Dir() (string, error) {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "echoshell"), nil
+}
+
+func NormalizeTarget(target string) string {
+	v := strings.TrimSpace(target)
+	if v == "" {
+		return DefaultTarget
+	}
+	if strings.EqualFold(v, "localhost") || strings.EqualFold(v, "root@localhost") {
+		return "local"
+	}
+	return v
+}
+
+func IsLocalTarget(target string) bool {
+	return NormalizeTarget(target) == DefaultTarget
+}
+
+// TmuxMouseMode returns the mouse mode from ECHOSHELL_TMUX_MOUSE and whether
+// echoshell should set it at all.
+func TmuxMouseMode() (string, bool) {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("ECHOSHELL_TMUX_MOUSE")))
+	switch v {
+	case "", "1", "on", "true", "yes":
+		return "on", true
+	case "0", "off", "false", "no":
+		return "off", true
+	case "keep", "auto":
+		return "", false
+	default:
+		return "on", true
+	}
+}
+
+func TmuxControlEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ECHOSHELL_TMUX_CONTROL"))) {
+	case "0", "off", "false", "no":
+		return false
+	}
+	return true
+}
+
+// Session naming schemes; see NameFormat.
+const (
+	// DefaultNameFormat names sessions like app-claude-1.
+	DefaultNameFormat = "{repo}-{command}-{n}"
+	// BranchNameFormat names sessions like app-feat-login-claude.
+	BranchNameFormat = "{repo}-{branch}-{command}"
+)
+
+// NameFormat is the session naming scheme from ECHOSHELL_NAME_FORMAT, with
+// the placeholders {repo}, {command}, {branch}, {date} and {n}. The presets
+// "default" and "branch" select DefaultNameFormat and BranchNameFormat.
+func NameFormat() string {
+	v := strings.TrimSpace(os.Getenv("ECHOSHELL_NAME_FORMAT"))
+	switch strings.ToLower(v) {
+	case "", "default":
+		return DefaultNameFormat
+	case "branch":
+		return BranchNameFormat
+	}
+	return v
+}
+
+// ReuseNumbers reports whether ECHOSHELL_REUSE_NUMBERS asks new sessions to
+// take the lowest free number rather than one past the highest.
+func ReuseNumbers() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ECHOSHELL_REUSE_NUMBERS"))) {
+	case "1", "on", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// EmptyEnterJumps reports whether ECHOSHELL_EMPTY_ENTER=jump asks Enter on a
+// repo without sessions to jump to the first repo that has some, instead of
+// creating a session from the repo's default template.
+func EmptyEnterJumps() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("ECHOSHELL_EMPTY_ENTER")), "jump")
+}
+
+// AutoRestore reports whether ECHOSHELL_AUTO_RESTORE asks to restore the saved
+// sessions when echoshell starts on a fresh tmux server.
+func AutoRestore() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ECHOSHELL_AUTO_RESTORE"))) {
+	case "1", "on", "true", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestTmuxMouseModeDefaultsOn(t *testing.T) {
+	t.Setenv("ECHOSHELL_TMUX_MOUSE", "")
+	mode, manage := TmuxMouseMode()
+	if !manage {
+		t.Fatalf("expected mouse mode to be managed by default")
+	}
+	if mode != "on" {
+		t.Fatalf("expected default mouse mode 'on', got %q", mode)
+	}
+}
+
+func TestTmuxMouseModeOff(t *testing.T) {
+	t.Setenv("ECHOSHELL_TMUX_MOUSE", "off")
+	mode, manage := TmuxMouseMode()
+	if !manage {
+		t.Fatalf("expected mouse mode to be managed for explicit off")
+	}
+	if mode != "off" {
+		t.Fatalf("expected mouse mode 'off', got %q", mode)
+	}
+}
+
+func TestTmuxMouseModeKeep(t *testing.T) {
+	t.Setenv("ECHOSHELL_TMUX_MOUSE", "keep")
+	mode, manage := TmuxMouseMode()
+	if manage {
+		t.Fatalf("expected keep mode to skip managing mouse, got %q", mode)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// envCommandTimeout bounds an env command so a prompt that never shows,
+// like pinentry inside tmux, cannot hang session creation.
+var envCommandTimeout = 8 * time.Second
+
+// Env maps variable names to values for a session's environment.
+type Env map[string]EnvValue
+
+// EnvValue is one env var: a literal Value, or read from File or the output
+// of Command when the session starts, so secrets stay out of config files
+// and shell history. In TOML a plain string is a literal:
+//
+//	RUST_LOG = "debug"
+//	GITHUB_TOKEN = { file = "~/.config/gh/token" }
+//	ANTHROPIC_API_KEY = { command = "pass show anthropic" }
+type EnvValue struct {
+	Value   string
+	File    string
+	Command string
+}
+
+// Literal is an EnvValue holding s.
+func Literal(s string) EnvValue {
+	return EnvValue{Value: s}
+}
+
+// UnmarshalTOML accepts a string or a table with exactly one of value, file
+// or command.
+func (v *EnvValue) UnmarshalTOML(data any) error {
+	switch d := data.(type) {
+	case string:
+		*v = EnvValue{Value: d}
+		return nil
+	case map[string]any:
+		if len(d) != 1 {
+			return errors.New("env table needs exactly one of value, file or command")
+		}
+		for k, raw := range d {
+			s, ok := raw.(string)
+			if !ok {
+				return fmt.Errorf("env %s must be a string", k)
+			}
+			switch k {
+			case "value":
+				*v = EnvValue{Value: s}
+			case "file":
+				*v = EnvValue{File: s}
+			case "command":
+				*v = EnvValue{Command: s}
+			default:
+				return fmt.Errorf("unknown env key %s", k)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("env value must be a string or table, got %T", data)
+}
+
+// Resolve produces the value, reading the file or running the command in dir.
+// Trailing newlines are dropped.
+func (v EnvValue) Resolve(dir string) (string, error) {
+	switch {
+	case v.File != "":
+		data, err := os.ReadFile(expandHome(v.File))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case v.Command != "":
+		ctx, cancel := context.WithTimeout(context.Background(), envCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", v.Command)
+		cmd.Dir = dir
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		// Children of sh, like gpg waiting on pinentry, can keep the pipes
+		// open after sh is killed; stop waiting for them.
+		cmd.WaitDelay = time.Second
+		err := cmd.Run()
+		msg := strings.TrimSpace(stderr.String())
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			err = fmt.Errorf("timed out after %s", envCommandTimeout)
+		case err == nil:
+			return strings.TrimRight(stdout.String(), "\r\n"), nil
+		}
+		if msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", v.Command, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", v.Command, err)
+	}
+	return v.Value, nil
+}
+
+// Resolve produces every value of e; see EnvValue.Resolve.
+func (e Env) Resolve(dir string) (map[string]string, error) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(e))
+	for k, v := range e {
+		s, err := v.Resolve(dir)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
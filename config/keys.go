@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// KeysFile is the keymap file under Dir.
+const KeysFile = "keys.toml"
+
+// KeyAction is something a key does in the picker's main view.
+type KeyAction struct {
+	Name string
+	// Short labels the action in the help line; empty leaves it to the ?
+	// overlay.
+	Short string
+	Help  string
+	Keys  []string
+}
+
+// KeyActions lists every bindable action with its default keys, in help
+// order.
+func KeyActions() []KeyAction {
+	return []KeyAction{
+		{Name: "up", Help: "move up", Keys: []string{"up", "k"}},
+		{Name: "down", Help: "move down", Keys: []string{"down", "j"}},
+		{Name: "prev-repo", Help: "previous repo", Keys: []string{"left", "shift+tab"}},
+		{Name: "next-repo", Short: "repo", Help: "next repo", Keys: []string{"right", "tab"}},
+		{Name: "page-up", Help: "page up", Keys: []string{"pgup"}},
+		{Name: "page-down", Help: "page down", Keys: []string{"pgdown"}},
+		{Name: "top", Help: "first row", Keys: []string{"home"}},
+		{Name: "bottom", Help: "last row", Keys: []string{"end"}},
+		{Name: "attach", Short: "attach", Help: "attach, or create on a repo without sessions", Keys: []string{"enter"}},
+		{Name: "new", Short: "new", Help: "new session from a template", Keys: []string{"ctrl+n"}},
+		{Name: "destroy", Short: "destroy", Help: "destroy selected session", Keys: []string{"d"}},
+		{Name: "rename", Short: "rename", Help: "rename selected session", Keys: []string{"e"}},
+		{Name: "restart", Short: "restart", Help: "restart selected session", Keys: []string{"x"}},
+		{Name: "fold", Short: "fold", Help: "collapse/expand repo", Keys: []string{"space"}},
+		{Name: "pin", Short: "pin", Help: "pin repo to the top", Keys: []string{"f"}},
+		{Name: "hide-empty", Help: "hide/show repos without sessions", Keys: []string{"z"}},
+		{Name: "refresh", Help: "refresh", Keys: []string{"r"}},
+		{Name: "menu", Short: "menu", Help: "menu", Keys: []string{"0"}},
+		{Name: "neovim", Help: "spawn neovim", Keys: []string{"n"}},
+		{Name: "opencode", Help: "spawn opencode", Keys: []string{"o"}},
+		{Name: "lazygit", Help: "spawn lazygit", Keys: []string{"l"}},
+		{Name: "claude", Short: "claude", Help: "spawn claude full", Keys: []string{"c"}},
+		{Name: "default", Short: "default", Help: "spawn the repo's default template", Keys: []string{"b"}},
+		{Name: "palette", Short: "commands", Help: "command palette", Keys: []string{"ctrl+p"}},
+		{Name: "help", Short: "keys", Help: "show all keys", Keys: []string{"?"}},
+		{Name: "quit", Short: "quit", Help: "quit", Keys: []string{"q", "esc"}},
+	}
+}
+
+// reservedKeys always do the same thing: 1-9 select a repo, ctrl+c quits.
+var reservedKeys = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "ctrl+c"}
+
+// Keymap binds action names to keys, written as bubbletea names them
+// ("ctrl+n", "pgdown", "space").
+type Keymap map[string][]string
+
+// DefaultKeymap is the keymap without a keys file.
+func DefaultKeymap() Keymap {
+	km := Keymap{}
+	for _, a := range KeyActions() {
+		km[a.Name] = a.Keys
+	}
+	return km
+}
+
+// KeysPath is where LoadKeymap reads overrides from.
+func KeysPath() (string, error) {
+	d, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, KeysFile), nil
+}
+
+// LoadKeymap is the default keymap with the keys file's bindings replacing
+// those of the actions it names:
+//
+//	down = ["j", "down"]
+//	lazygit = "ctrl+g"
+//	pin = []
+//
+// Unknown actions and keys bound twice are errors.
+func LoadKeymap() (Keymap, error) {
+	km := DefaultKeymap()
+	path, err := KeysPath()
+	if err != nil {
+		return km, nil
+	}
+	raw := map[string]any{}
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return km, nil
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, v := range raw {
+		if _, ok := km[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown action %q", path, name)
+		}
+		keys, err := keyList(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+		km[name] = keys
+	}
+	if err := km.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return km, nil
+}
+
+func keyList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{normalizeKey(v)}, nil
+	case []any:
+		keys := make([]string, 0, len(v))
+		for _, k := range v {
+			s, ok := k.(string)
+			if !ok {
+				return nil, errors.New("keys must be strings")
+			}
+			keys = append(keys, normalizeKey(s))
+		}
+		return keys, nil
+	}
+	return nil, errors.New("want a key or a list of keys")
+}
+
+// normalizeKey lowercases like the picker's key matching and spells the
+// space bar "space".
+func normalizeKey(k string) string {
+	if k == " " {
+		return "space"
+	}
+	return strings.ToLower(strings.TrimSpace(k))
+}
+
+// Validate reports empty keys, reserved keys and keys bound to more than one
+// action.
+func (km Keymap) Validate() error {
+	owner := map[string]string{}
+	var errs []error
+	for _, a := range KeyActions() {
+		for _, k := range km[a.Name] {
+			switch {
+			case k == "":
+				errs = append(errs, fmt.Errorf("%s: empty key", a.Name))
+			case slices.Contains(reservedKeys, k):
+				errs = append(errs, fmt.Errorf("%s: %q is reserved", a.Name, k))
+			case owner[k] != "" && owner[k] != a.Name:
+				errs = append(errs, fmt.Errorf("%q is bound to both %s and %s", k, owner[k], a.Name))
+			default:
+				owner[k] = a.Name
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Action is the action bound to key, as bubbletea names it, or "".
+func (km Keymap) Action(key string) string {
+	key = strings.ToLower(key)
+	if key == " " {
+		key = "space"
+	}
+	for _, a := range KeyActions() {
+		if slices.Contains(km[a.Name], key) {
+			return a.Name
+		}
+	}
+	return ""
+}
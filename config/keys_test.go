@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultKeymapIsValid(t *testing.T) {
+	km := DefaultKeymap()
+	if err := km.Validate(); err != nil {
+		t.Fatalf("unexpected conflict in defaults: %v", err)
+	}
+	if km.Action("j") != "down" || km.Action(" ") != "fold" || km.Action("ctrl+c") != "" || km.Action("l") != "lazygit" {
+		t.Fatalf("unexpected default bindings")
+	}
+}
+
+func TestLoadKeymapOverridesAndRejectsConflicts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := KeysPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("lazygit = \"ctrl+g\"\npin = []\nfold = [\" \", \"v\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	km, err := LoadKeymap()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if km.Action("ctrl+g") != "lazygit" || km.Action("l") != "" || km.Action("f") != "" || km.Action("v") != "fold" || km.Action(" ") != "fold" {
+		t.Fatalf("unexpected keymap: %#v", km)
+	}
+
+	if err := os.WriteFile(path, []byte("lazygit = \"d\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeymap(); err == nil || !strings.Contains(err.Error(), "bound to both") {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("teleport = \"t\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeymap(); err == nil {
+		t.Fatalf("expected unknown action to be an error")
+	}
+
+	if err := os.WriteFile(path, []byte("menu = \"1\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeymap(); err == nil {
+		t.Fatalf("expected a reserved key to be an error")
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RepoFile is the optional per-repo settings file, read from the repo root.
+const RepoFile = ".echoshell.toml"
+
+// Repo is a repo's .echoshell.toml:
+//
+//	default = "dev"              # template for b and Enter on an empty repo
+//	hide = false                 # leave the repo out of the picker
+//	pre = ["nix develop"]        # typed before every template's command
+//
+//	[env]                        # see EnvValue
+//	RUST_LOG = "debug"
+//	API_KEY = { command = "pass show api" }
+//
+//	[[templates]]
+//	name = "dev"
+//	command = "npm run dev"
+type Repo struct {
+	Default   string     `toml:"default"`
+	Hide      bool       `toml:"hide"`
+	Env       Env        `toml:"env"`
+	Pre       []string   `toml:"pre"`
+	Templates []Template `toml:"templates"`
+}
+
+type repoEntry struct {
+	mod  time.Time
+	size int64
+	repo Repo
+	err  error
+}
+
+var (
+	repoCache   = map[string]repoEntry{}
+	repoCacheMu sync.Mutex
+)
+
+// LoadRepo reads dir's .echoshell.toml. A missing file is an empty Repo.
+// Results are cached per repo until the file's mtime or size changes.
+func LoadRepo(dir string) (Repo, error) {
+	path := filepath.Join(dir, RepoFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		repoCacheMu.Lock()
+		delete(repoCache, path)
+		repoCacheMu.Unlock()
+		if os.IsNotExist(err) {
+			return Repo{}, nil
+		}
+		return Repo{}, err
+	}
+
+	repoCacheMu.Lock()
+	e, ok := repoCache[path]
+	repoCacheMu.Unlock()
+	if ok && e.mod.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.repo, e.err
+	}
+
+	r, err := parseRepo(path)
+	repoCacheMu.Lock()
+	repoCache[path] = repoEntry{mod: info.ModTime(), size: info.Size(), repo: r, err: err}
+	repoCacheMu.Unlock()
+	return r, err
+}
+
+func parseRepo(path string) (Repo, error) {
+	var r Repo
+	md, err := toml.DecodeFile(path, &r)
+	if err != nil {
+		return Repo{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return Repo{}, fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+	}
+	for i, t := range r.Templates {
+		if strings.TrimSpace(t.Name) == "" {
+			return Repo{}, fmt.Errorf("%s: template %d has no name", path, i+1)
+		}
+	}
+	return r, nil
+}
+
+// Resolve returns base with the repo's templates applied: same-named ones
+// replace the base template, others are appended. Every template then gets
+// the repo's pre-commands (run first) and env. The repo's env overrides that
+// of base templates; the repo's own templates override the repo's env.
+func (r Repo) Resolve(base []Template) []Template {
+	out := make([]Template, len(base))
+	copy(out, base)
+	fromRepo := map[string]bool{}
+	for _, t := range r.Templates {
+		if strings.TrimSpace(t.Label) == "" {
+			t.Label = t.Name
+		}
+		fromRepo[t.Name] = true
+		replaced := false
+		for i := range out {
+			if out[i].Name == t.Name {
+				out[i] = t
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out = append(out, t)
+		}
+	}
+	if len(r.Env) == 0 && len(r.Pre) == 0 {
+		return out
+	}
+	for i := range out {
+		layers := []Env{out[i].Env, r.Env}
+		if fromRepo[out[i].Name] {
+			layers = []Env{r.Env, out[i].Env}
+		}
+		env := Env{}
+		for _, layer := range layers {
+			for k, v := range layer {
+				env[k] = v
+			}
+		}
+		out[i].Env = env
+		out[i].Pre = append(append([]string{}, r.Pre...), out[i].Pre...)
+	}
+	return out
+}
+
+// DefaultTemplate is the template named by default, else shell, else the
+// first of templates.
+func (r Repo) DefaultTemplate(templates []Template) Template {
+	for _, name := range []string{r.Default, "shell"} {
+		if name == "" {
+			continue
+		}
+		for _, t := range templates {
+			if t.Name == name {
+				return t
+			}
+		}
+	}
+	if len(templates) > 0 {
+		return templates[0]
+	}
+	return Template{Label: "shell", Name: "shell"}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRepoRereadsChangedFile(t *testing.T) {
+	dir := t.TempDir()
+	if r, err := LoadRepo(dir); err != nil || r.Default != "" {
+		t.Fatalf("expected empty config without a file, got %#v %v", r, err)
+	}
+
+	path := filepath.Join(dir, RepoFile)
+	if err := os.WriteFile(path, []byte("default = \"dev\"\n\n[[templates]]\nname = \"dev\"\ncommand = \"npm run dev\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadRepo(dir)
+	if err != nil || r.Default != "dev" || len(r.Templates) != 1 {
+		t.Fatalf("expected parsed config, got %#v %v", r, err)
+	}
+
+	if err := os.WriteFile(path, []byte("hide = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := LoadRepo(dir); err != nil || !r.Hide || r.Default != "" {
+		t.Fatalf("expected reread after change, got %#v %v", r, err)
+	}
+
+	if err := os.WriteFile(path, []byte("colour = \"red\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRepo(dir); err == nil {
+		t.Fatalf("expected unknown key to be an error")
+	}
+}
+
+func TestRepoResolveOverridesAndAddsTemplates(t *testing.T) {
+	r := Repo{
+		Env: Env{"A": Literal("repo"), "IS_SANDBOX": Literal("0")},
+		Pre: []string{"direnv allow"},
+		Templates: []Template{
+			{Name: "shell", Command: "zsh", Env: Env{"A": Literal("tpl")}},
+			{Name: "dev", Command: "npm run dev"},
+		},
+	}
+	got := r.Resolve(DefaultTemplates())
+	if len(got) != len(DefaultTemplates())+1 {
+		t.Fatalf("expected one added template, got %d", len(got))
+	}
+	if shell := got[0]; shell.Command != "zsh" || shell.Env["A"].Value != "tpl" {
+		t.Fatalf("expected repo template env to win over repo env, got %#v", shell)
+	}
+	for _, tpl := range got {
+		if tpl.Name == "claude-full" && tpl.Env["IS_SANDBOX"].Value != "0" {
+			t.Fatalf("expected repo env to override built-in template env, got %#v", tpl.Env)
+		}
+	}
+	if dev := got[len(got)-1]; dev.Label != "dev" || len(dev.Pre) != 1 {
+		t.Fatalf("expected added template labelled by name with pre-commands, got %#v", dev)
+	}
+	if r.DefaultTemplate(got).Name != "shell" {
+		t.Fatalf("expected shell as default without a default key")
+	}
+}
+
+func TestLoadRepoParsesEnvSources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	body := "[env]\nPLAIN = \"1\"\nFILE = { file = \"" + filepath.Join(dir, "token") + "\" }\nCMD = { command = \"echo from-command\" }\n"
+	if err := os.WriteFile(filepath.Join(dir, RepoFile), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadRepo(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env, err := r.Env.Resolve(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env["PLAIN"] != "1" || env["FILE"] != "from-file" || env["CMD"] != "from-command" {
+		t.Fatalf("unexpected env: %#v", env)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, RepoFile), []byte("[env]\nX = { path = \"y\" }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, RepoFile), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRepo(dir); err == nil {
+		t.Fatalf("expected unknown env source to be an error")
+	}
+}
+
+func TestEnvCommandTimesOutAndReportsStderr(t *testing.T) {
+	defer func(d time.Duration) { envCommandTimeout = d }(envCommandTimeout)
+	envCommandTimeout = 200 * time.Millisecond
+	dir := t.TempDir()
+
+	began := time.Now()
+	_, err := Env{"TOKEN": {Command: "echo waiting >&2; sleep 5"}}.Resolve(dir)
+	if err == nil || !strings.Contains(err.Error(), "env TOKEN") || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "waiting") {
+		t.Fatalf("expected a timeout naming the var with stderr, got %v", err)
+	}
+	if time.Since(began) > 3*time.Second {
+		t.Fatalf("expected the command to be cut off, took %s", time.Since(began))
+	}
+
+	_, err = Env{"TOKEN": {Command: "echo no such entry >&2; exit 3"}}.Resolve(dir)
+	if err == nil || !strings.Contains(err.Error(), "env TOKEN") || !strings.Contains(err.Error(), "no such entry") {
+		t.Fatalf("expected the failure with stderr, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ThemeFile is the color config file under Dir.
+const ThemeFile = "theme.toml"
+
+// Theme holds the picker's colors as ANSI 256 numbers or #rrggbb. An empty
+// color leaves the terminal's default.
+type Theme struct {
+	Name          string   `toml:"theme"`
+	Title         string   `toml:"title"`
+	Heading       string   `toml:"heading"`
+	Help          string   `toml:"help"`
+	Status        string   `toml:"status"`
+	Text          string   `toml:"text"`
+	Selected      string   `toml:"selected"`
+	SelectedBg    string   `toml:"selected_bg"`
+	Border        string   `toml:"border"`
+	Preview       string   `toml:"preview"`
+	PreviewBg     string   `toml:"preview_bg"`
+	PreviewBorder string   `toml:"preview_border"`
+	Repos         []string `toml:"repos"`
+}
+
+// Theme names.
+const (
+	ThemeDark         = "dark"
+	ThemeLight        = "light"
+	ThemeHighContrast = "high-contrast"
+	ThemeNoColor      = "no-color"
+)
+
+// Themes are the built-in themes by name.
+func Themes() map[string]Theme {
+	return map[string]Theme{
+		ThemeDark: {
+			Name: ThemeDark, Title: "205", Heading: "220", Help: "246", Status: "111", Text: "250",
+			Selected: "230", SelectedBg: "62", Border: "240",
+			Preview: "249", PreviewBg: "236", PreviewBorder: "238",
+			Repos: []string{"81", "112", "178", "203", "75", "141", "214", "219", "69"},
+		},
+		ThemeLight: {
+			Name: ThemeLight, Title: "162", Heading: "130", Help: "242", Status: "25", Text: "236",
+			Selected: "231", SelectedBg: "25", Border: "248",
+			Preview: "238", PreviewBg: "254", PreviewBorder: "250",
+			Repos: []string{"25", "28", "130", "124", "31", "91", "166", "127", "61"},
+		},
+		ThemeHighContrast: {
+			Name: ThemeHighContrast, Title: "201", Heading: "226", Help: "255", Status: "51", Text: "255",
+			Selected: "16", SelectedBg: "226", Border: "255",
+			Preview: "255", PreviewBg: "16", PreviewBorder: "255",
+			Repos: []string{"51", "46", "226", "196", "201", "214", "39", "213", "118"},
+		},
+		ThemeNoColor: {Name: ThemeNoColor},
+	}
+}
+
+// ThemePath is where LoadTheme reads the theme choice and overrides from.
+func ThemePath() (string, error) {
+	d, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, ThemeFile), nil
+}
+
+// LoadTheme picks the theme named by ECHOSHELL_THEME, else the theme file's
+// theme key, else dark, and applies the file's colors on top:
+//
+//	theme = "light"
+//	heading = "#af5f00"
+//	repos = ["25", "28", "130"]
+//
+// A non-empty NO_COLOR always selects no-color and ignores overrides.
+func LoadTheme() (Theme, error) {
+	themes := Themes()
+	if os.Getenv("NO_COLOR") != "" {
+		return themes[ThemeNoColor], nil
+	}
+	var file Theme
+	path, err := ThemePath()
+	if err == nil {
+		md, err := toml.DecodeFile(path, &file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Theme{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if keys := md.Undecoded(); len(keys) > 0 {
+			return Theme{}, fmt.Errorf("%s: unknown key %s", path, keys[0])
+		}
+	}
+
+	name := strings.TrimSpace(os.Getenv("ECHOSHELL_THEME"))
+	if name == "" {
+		name = file.Name
+	}
+	if name == "" {
+		name = ThemeDark
+	}
+	t, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	t = t.with(file)
+	if err := t.validate(); err != nil {
+		return Theme{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// with overrides t's colors with the non-empty ones in o.
+func (t Theme) with(o Theme) Theme {
+	for _, c := range []struct{ dst, src *string }{
+		{&t.Title, &o.Title}, {&t.Heading, &o.Heading}, {&t.Help, &o.Help},
+		{&t.Status, &o.Status}, {&t.Text, &o.Text}, {&t.Selected, &o.Selected},
+		{&t.SelectedBg, &o.SelectedBg}, {&t.Border, &o.Border}, {&t.Preview, &o.Preview},
+		{&t.PreviewBg, &o.PreviewBg}, {&t.PreviewBorder, &o.PreviewBorder},
+	} {
+		if *c.src != "" {
+			*c.dst = *c.src
+		}
+	}
+	if len(o.Repos) > 0 {
+		t.Repos = slices.Clone(o.Repos)
+	}
+	return t
+}
+
+func (t Theme) validate() error {
+	colors := append([]string{t.Title, t.Heading, t.Help, t.Status, t.Text, t.Selected,
+		t.SelectedBg, t.Border, t.Preview, t.PreviewBg, t.PreviewBorder}, t.Repos...)
+	for _, c := range colors {
+		if c != "" && !validColor(c) {
+			return fmt.Errorf("bad color %q: want 0-255 or #rrggbb", c)
+		}
+	}
+	return nil
+}
+
+func validColor(c string) bool {
+	if hex, ok := strings.CutPrefix(c, "#"); ok {
+		_, err := strconv.ParseUint(hex, 16, 32)
+		return err == nil && (len(hex) == 3 || len(hex) == 6)
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// RepoColor is name's color from the repos palette, "" without one. The
+// FNV-1a hash keeps it stable across runs and tells anagrams apart.
+func (t Theme) RepoColor(name string) string {
+	if len(t.Repos) == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return t.Repos[h.Sum32()%uint32(len(t.Repos))]
+}
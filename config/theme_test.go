@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeThemeFile(t *testing.T, body string) {
+	t.Helper()
+	path, err := ThemePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadThemePicksAndOverrides(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("ECHOSHELL_THEME", "")
+	if th, err := LoadTheme(); err != nil || th.Name != ThemeDark {
+		t.Fatalf("expected dark without config, got %q %v", th.Name, err)
+	}
+
+	writeThemeFile(t, "theme = \"light\"\nheading = \"#af5f00\"\nrepos = [\"1\", \"2\"]\n")
+	th, err := LoadTheme()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if th.Name != ThemeLight || th.Heading != "#af5f00" || th.Border != Themes()[ThemeLight].Border || len(th.Repos) != 2 {
+		t.Fatalf("expected light with overrides, got %#v", th)
+	}
+
+	t.Setenv("ECHOSHELL_THEME", ThemeHighContrast)
+	if th, _ := LoadTheme(); th.Name != ThemeHighContrast || th.Heading != "#af5f00" {
+		t.Fatalf("expected env to pick the theme under the overrides, got %#v", th)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if th, _ := LoadTheme(); th.Name != ThemeNoColor || th.Heading != "" || th.RepoColor("app") != "" {
+		t.Fatalf("expected NO_COLOR to win, got %#v", th)
+	}
+}
+
+func TestLoadThemeRejectsBadConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("ECHOSHELL_THEME", "solarized")
+	if _, err := LoadTheme(); err == nil {
+		t.Fatalf("expected unknown theme to be an error")
+	}
+	t.Setenv("ECHOSHELL_THEME", "")
+	for _, body := range []string{"heading = \"orange\"\n", "colour = \"1\"\n", "repos = [\"300\"]\n"} {
+		writeThemeFile(t, body)
+		if _, err := LoadTheme(); err == nil {
+			t.Fatalf("expected %q to be an error", body)
+		}
+	}
+}
+
+func TestRepoColorIsStableAndSeparatesAnagrams(t *testing.T) {
+	th := Themes()[ThemeDark]
+	if th.RepoColor("api") != th.RepoColor("api") {
+		t.Fatalf("expected a stable color")
+	}
+	if th.RepoColor("api") == th.RepoColor("pia") {
+		t.Fatalf("expected anagrams to get different colors")
+	}
+}
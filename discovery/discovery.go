@@ -0,0 +1,497 @@
+// Package discovery finds the repos under ~/git on a target and attributes
+// tmux sessions to them.
+package discovery
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"echoshell/config"
+	"echoshell/tmux"
+)
+
+var repoGroupCache = map[string][]WorkspaceGroup{}
+
+var repoGroupCacheMu sync.RWMutex
+
+// Attribution rules, strongest first. A session matching none of them falls
+// back to the root group with an empty Attribution.
+const (
+	// AttributedPinned: the @echoshell_repo option names the repo.
+	AttributedPinned = "pinned"
+	// AttributedStartDir: the session was started inside the repo.
+	AttributedStartDir = "start-dir"
+	// AttributedPanes: most of the session's panes are inside the repo.
+	AttributedPanes = "panes"
+)
+
+type SessionInfo struct {
+	Name        string
+	Workdir     string
+	Command     string
+	Attached    bool
+	Windows     int
+	Activity    int64
+	Attribution string
+	Meta        Meta
+	// Branch is the branch checked out at Workdir, looked up only for
+	// sessions named after a branch (Meta.Branch).
+	Branch string
+}
+
+// Meta is what echoshell recorded in the session's @echoshell_* options when
+// it created the session. Sessions made outside echoshell have none.
+type Meta struct {
+	Template string
+	Command  string
+	Resume   string
+	// Branch is the git branch the session was named after.
+	Branch   string
+	RepoPath string
+	Host     string
+	Creator  string
+}
+
+func metaFromOptions(options map[string]string) Meta {
+	return Meta{
+		Template: options[tmux.OptionTemplate],
+		Command:  options[tmux.OptionCommand],
+		Resume:   options[tmux.OptionResume],
+		Branch:   options[tmux.OptionBranch],
+		RepoPath: options[tmux.OptionRepo],
+		Host:     options[tmux.OptionHost],
+		Creator:  options[tmux.OptionCreator],
+	}
+}
+
+type WorkspaceGroup struct {
+	Workspace string
+	Repo      string
+	Name      string
+	Path      string
+	Sessions  []SessionInfo
+	// Config is the repo's .echoshell.toml; ConfigErr is set when it could
+	// not be read, leaving Config empty.
+	Config    config.Repo
+	ConfigErr error
+}
+
+// GroupSessions lists the sessions on b and files each one under a repo,
+// recording which rule placed it there (see attribute). The session
+// echoshell runs in is left out, since the picker cannot switch to itself.
+func GroupSessions(b tmux.Backend, target string) ([]WorkspaceGroup, error) {
+	return groupSessions(b, target, true)
+}
+
+// GroupAllSessions is GroupSessions including the session echoshell runs in,
+// for the CLI and snapshots.
+func GroupAllSessions(b tmux.Backend, target string) ([]WorkspaceGroup, error) {
+	return groupSessions(b, target, false)
+}
+
+func groupSessions(b tmux.Backend, target string, hideCurrent bool) ([]WorkspaceGroup, error) {
+	groups, err := RepoGroupsCached(target)
+	if err != nil {
+		return nil, err
+	}
+	currentSession := ""
+	if hideCurrent && config.IsLocalTarget(target) {
+		currentSession = tmux.CurrentSession(b)
+	}
+
+	sessions, err := b.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return withoutHidden(groups), nil
+	}
+
+	panes, _ := b.ListPanes()
+	panesBySession := map[string][]tmux.Pane{}
+	for _, p := range panes {
+		if p.Session != "" {
+			panesBySession[p.Session] = append(panesBySession[p.Session], p)
+		}
+	}
+
+	if len(groups) == 0 {
+		groups = []WorkspaceGroup{{Workspace: "root", Repo: "root", Name: "root", Path: "/", Sessions: nil}}
+	}
+
+	for _, ts := range sessions {
+		name := ts.Name
+		sessionPanes := panesBySession[name]
+		workdir, command := "", ""
+		if first, ok := firstPane(sessionPanes); ok {
+			workdir = strings.TrimSpace(first.Path)
+			command = strings.TrimSpace(first.Command)
+		}
+		if IsBootstrapSession(name) {
+			continue
+		}
+		if strings.EqualFold(command, "echoshell") {
+			continue
+		}
+		if currentSession != "" && name == currentSession {
+			continue
+		}
+		best, rule := attribute(groups, ts, sessionPanes)
+		info := SessionInfo{
+			Name:        name,
+			Workdir:     workdir,
+			Command:     command,
+			Attached:    ts.Attached,
+			Windows:     ts.Windows,
+			Activity:    ts.Activity,
+			Attribution: rule,
+			Meta:        metaFromOptions(ts.Options),
+		}
+		if info.Meta.Branch != "" && workdir != "" && config.IsLocalTarget(target) {
+			info.Branch = CachedBranch(workdir)
+		}
+		groups[best].Sessions = append(groups[best].Sessions, info)
+	}
+
+	for i := range groups {
+		sort.Slice(groups[i].Sessions, func(a, b int) bool {
+			return groups[i].Sessions[a].Name < groups[i].Sessions[b].Name
+		})
+	}
+
+	return withoutHidden(groups), nil
+}
+
+// withoutHidden drops repos whose .echoshell.toml sets hide, unless sessions
+// still run in them.
+func withoutHidden(groups []WorkspaceGroup) []WorkspaceGroup {
+	out := groups[:0]
+	for _, g := range groups {
+		if g.Config.Hide && len(g.Sessions) == 0 {
+			continue
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+// attribute picks the group for ts: the pinned repo, else the repo containing
+// the session's start directory, else the repo most of its panes are in.
+func attribute(groups []WorkspaceGroup, ts tmux.Session, panes []tmux.Pane) (int, string) {
+	if pin := strings.TrimSpace(ts.Options[tmux.OptionRepo]); pin != "" {
+		for i := 1; i < len(groups); i++ {
+			if groups[i].Path == pin || groups[i].Repo == pin {
+				return i, AttributedPinned
+			}
+		}
+	}
+	if i := groupForPath(groups, ts.Path); i > 0 {
+		return i, AttributedStartDir
+	}
+	votes := map[int]int{}
+	best := 0
+	for _, p := range panes {
+		i := groupForPath(groups, p.Path)
+		if i == 0 {
+			continue
+		}
+		votes[i]++
+		if votes[i] > votes[best] {
+			best = i
+		}
+	}
+	if best > 0 {
+		return best, AttributedPanes
+	}
+	return 0, ""
+}
+
+// groupForPath returns the group whose path is the longest prefix of path,
+// or 0 (the root fallback).
+func groupForPath(groups []WorkspaceGroup, path string) int {
+	path = strings.TrimSpace(path)
+	best := 0
+	bestLen := 0
+	for i := 1; i < len(groups); i++ {
+		gp := strings.TrimSpace(groups[i].Path)
+		if gp == "" || gp == "/" {
+			continue
+		}
+		if HasPathPrefix(path, gp) && len(gp) > bestLen {
+			best = i
+			bestLen = len(gp)
+		}
+	}
+	return best
+}
+
+// firstPane is pane 0 of the session's lowest-numbered window.
+func firstPane(panes []tmux.Pane) (tmux.Pane, bool) {
+	found := false
+	var first tmux.Pane
+	for _, p := range panes {
+		if !found || p.Window < first.Window || (p.Window == first.Window && p.Index < first.Index) {
+			first = p
+			found = true
+		}
+	}
+	return first, found
+}
+
+func RepoGroups(target string) ([]WorkspaceGroup, error) {
+	root := remoteGitRoot(target)
+	groups := []WorkspaceGroup{{Workspace: "root", Repo: "root", Name: "root", Path: "/", Sessions: nil}}
+
+	repos, err := remoteListDirNames(target, root)
+	if err != nil {
+		return groups, nil
+	}
+	for _, repo := range repos {
+		groups = append(groups, WorkspaceGroup{
+			Workspace: "git",
+			Repo:      repo,
+			Name:      "git/" + repo,
+			Path:      filepath.Join(root, repo),
+			Sessions:  nil,
+		})
+	}
+
+	sort.Slice(groups[1:], func(i, j int) bool {
+		return groups[1+i].Name < groups[1+j].Name
+	})
+	return groups, nil
+}
+
+func remoteGitRoot(target string) string {
+	if config.IsLocalTarget(target) {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			home = strings.TrimSpace(home)
+			if home != "" {
+				return filepath.Join(home, "git")
+			}
+		}
+		return "/root/git"
+	}
+
+	out, err := tmux.RunSSH(target, `printf %s "$HOME"`)
+	if err != nil {
+		return "/root/git"
+	}
+	home := strings.TrimSpace(out)
+	if home == "" {
+		return "/root/git"
+	}
+	return filepath.Join(home, "git")
+}
+
+// RepoGroupsCached is RepoGroups memoized per target for the life of the
+// process. The returned groups never carry sessions. Each repo's
+// .echoshell.toml is reread whenever it changes (local targets only).
+func RepoGroupsCached(target string) ([]WorkspaceGroup, error) {
+	repoGroupCacheMu.RLock()
+	groups, ok := repoGroupCache[target]
+	repoGroupCacheMu.RUnlock()
+	if ok {
+		out := make([]WorkspaceGroup, len(groups))
+		copy(out, groups)
+		for i := range out {
+			out[i].Sessions = nil
+		}
+		loadRepoConfigs(target, out)
+		return out, nil
+	}
+
+	groups, err := RepoGroups(target)
+	if err != nil {
+		return nil, err
+	}
+	copyGroups := make([]WorkspaceGroup, len(groups))
+	copy(copyGroups, groups)
+	repoGroupCacheMu.Lock()
+	repoGroupCache[target] = copyGroups
+	repoGroupCacheMu.Unlock()
+
+	for i := range groups {
+		groups[i].Sessions = nil
+	}
+	loadRepoConfigs(target, groups)
+	return groups, nil
+}
+
+func loadRepoConfigs(target string, groups []WorkspaceGroup) {
+	if !config.IsLocalTarget(target) {
+		return
+	}
+	for i := range groups {
+		if groups[i].Workspace != "git" {
+			continue
+		}
+		groups[i].Config, groups[i].ConfigErr = config.LoadRepo(groups[i].Path)
+	}
+}
+
+func remoteListDirNames(target, path string) ([]string, error) {
+	if config.IsLocalTarget(target) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		out := []string{}
+		for _, e := range entries {
+			if e.IsDir() {
+				out = append(out, e.Name())
+			}
+		}
+		sort.Strings(out)
+		return out, nil
+	}
+
+	cmd := "root=" + tmux.ShellQuote(path) + "; [ -d \"$root\" ] || exit 0; for d in \"$root\"/*; do [ -d \"$d\" ] || continue; basename \"$d\"; done"
+	out, err := tmux.RunSSH(target, cmd)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	for _, ln := range strings.Split(strings.TrimSpace(out), "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln != "" {
+			lines = append(lines, ln)
+		}
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// SetCache replaces the cached repo groups for target. Tests use it to avoid
+// scanning the filesystem.
+func SetCache(target string, groups []WorkspaceGroup) {
+	repoGroupCacheMu.Lock()
+	defer repoGroupCacheMu.Unlock()
+	if groups == nil {
+		delete(repoGroupCache, target)
+		return
+	}
+	repoGroupCache[target] = groups
+}
+
+func WorkspaceName(g WorkspaceGroup) string {
+	ws := strings.TrimSpace(g.Workspace)
+	if ws == "" {
+		return "root"
+	}
+	return ws
+}
+
+func HasPathPrefix(path, prefix string) bool {
+	p := filepath.Clean(strings.TrimSpace(path))
+	pr := filepath.Clean(strings.TrimSpace(prefix))
+	if p == "" || pr == "" {
+		return false
+	}
+	if p == pr {
+		return true
+	}
+	return strings.HasPrefix(p, pr+string(os.PathSeparator))
+}
+
+type branchEntry struct {
+	mod    time.Time
+	size   int64
+	branch string
+}
+
+var (
+	branchCache   = map[string]branchEntry{}
+	branchCacheMu sync.Mutex
+)
+
+// CachedBranch is Branch for the refreshes that run on every tmux event:
+// git only runs again once the repo's HEAD file changes.
+func CachedBranch(path string) string {
+	head := gitHead(path)
+	if head == "" {
+		return Branch(path)
+	}
+	info, err := os.Stat(head)
+	if err != nil {
+		return Branch(path)
+	}
+	branchCacheMu.Lock()
+	e, ok := branchCache[head]
+	branchCacheMu.Unlock()
+	if ok && e.mod.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.branch
+	}
+	branch := Branch(path)
+	branchCacheMu.Lock()
+	branchCache[head] = branchEntry{mod: info.ModTime(), size: info.Size(), branch: branch}
+	branchCacheMu.Unlock()
+	return branch
+}
+
+// gitHead finds the HEAD file of the repo containing path, following the
+// .git file of a worktree; "" outside a repo.
+func gitHead(path string) string {
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return filepath.Join(dotGit, "HEAD")
+			}
+			data, err := os.ReadFile(dotGit)
+			if err != nil {
+				return ""
+			}
+			gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+			if !ok {
+				return ""
+			}
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return filepath.Join(gitDir, "HEAD")
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// Branch is the checked-out branch of the git repo at path, or "" when path
+// is not in a repo or HEAD is detached.
+func Branch(path string) string {
+	out, err := exec.Command("git", "-C", path, "symbolic-ref", "--quiet", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func IsBootstrapSession(name string) bool {
+	n := strings.TrimSpace(name)
+	if n == "echoshell" {
+		return true
+	}
+	if !strings.HasPrefix(n, "echoshell-") {
+		return false
+	}
+	suffix := strings.TrimPrefix(n, "echoshell-")
+	if suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
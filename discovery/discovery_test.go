@@ -0,0 +1,187 @@
+package discovery
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"echoshell/config"
+	"echoshell/tmux"
+	"echoshell/tmux/tmuxtest"
+)
+
+func TestGroupSessionsAttributesByPathAndHidesCurrent(t *testing.T) {
+	f := tmuxtest.NewFake(
+		tmuxtest.Session{Name: "app-shell-1", Path: "/git/app/sub"},
+		tmuxtest.Session{Name: "scratch", Path: "/tmp"},
+		tmuxtest.Session{Name: "picker", Path: "/git/app"},
+		tmuxtest.Session{Name: "echoshell", Path: "/"},
+	)
+	f.Current = "picker"
+	SetCache("local", []WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app"},
+	})
+	t.Cleanup(func() { SetCache("local", nil) })
+	t.Setenv("TMUX_PANE", "%99")
+
+	groups, err := GroupSessions(f, "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups[0].Sessions) != 1 || groups[0].Sessions[0].Name != "scratch" {
+		t.Fatalf("unexpected root sessions: %#v", groups[0].Sessions)
+	}
+	if len(groups[1].Sessions) != 1 || groups[1].Sessions[0].Name != "app-shell-1" {
+		t.Fatalf("unexpected app sessions: %#v", groups[1].Sessions)
+	}
+
+	all, err := GroupAllSessions(f, "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all[1].Sessions) != 2 || all[1].Sessions[1].Name != "picker" {
+		t.Fatalf("expected the current session to be listed, got %#v", all[1].Sessions)
+	}
+}
+
+func TestGroupSessionsAttributionRules(t *testing.T) {
+	f := tmuxtest.NewFake(
+		tmuxtest.Session{Name: "pinned", Path: "/tmp", Dir: "/git/app", Options: map[string]string{tmux.OptionRepo: "/git/tools", tmux.OptionTemplate: "claude"}},
+		tmuxtest.Session{Name: "started", Path: "/tmp", Dir: "/git/app/sub"},
+		tmuxtest.Session{Name: "voted", Path: "/git/app", Dir: "/root", Panes: []string{"/git/tools", "/git/tools/cmd"}},
+		tmuxtest.Session{Name: "stray", Path: "/tmp", Dir: "/root"},
+	)
+	SetCache("local", []WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app"},
+		{Workspace: "git", Repo: "tools", Name: "git/tools", Path: "/git/tools"},
+	})
+	t.Cleanup(func() { SetCache("local", nil) })
+
+	groups, err := GroupSessions(f, "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]string{}
+	for _, g := range groups {
+		for _, s := range g.Sessions {
+			got[s.Name] = g.Repo + ":" + s.Attribution
+			if s.Name == "pinned" && (s.Meta.Template != "claude" || s.Meta.RepoPath != "/git/tools") {
+				t.Fatalf("expected metadata on pinned session, got %#v", s.Meta)
+			}
+		}
+	}
+	want := map[string]string{
+		"pinned":  "tools:" + AttributedPinned,
+		"started": "app:" + AttributedStartDir,
+		"voted":   "tools:" + AttributedPanes,
+		"stray":   "root:",
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Fatalf("%s attributed to %q, want %q", name, got[name], w)
+		}
+	}
+}
+
+func TestGroupSessionsHidesRepoUnlessItHasSessions(t *testing.T) {
+	dir := t.TempDir()
+	for _, repo := range []string{"busy", "quiet"} {
+		if err := os.MkdirAll(filepath.Join(dir, repo), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, repo, config.RepoFile), []byte("hide = true\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := tmuxtest.NewFake(tmuxtest.Session{Name: "busy-shell-1", Path: filepath.Join(dir, "busy")})
+	SetCache("local", []WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "busy", Name: "git/busy", Path: filepath.Join(dir, "busy")},
+		{Workspace: "git", Repo: "quiet", Name: "git/quiet", Path: filepath.Join(dir, "quiet")},
+	})
+	t.Cleanup(func() { SetCache("local", nil) })
+
+	groups, err := GroupSessions(f, "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != 2 || groups[1].Repo != "busy" || !groups[1].Config.Hide {
+		t.Fatalf("expected only the hidden repo with sessions to remain, got %#v", groups)
+	}
+}
+
+func TestGroupSessionsLooksUpBranchOfBranchNamedSessions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	f := tmuxtest.NewFake(
+		tmuxtest.Session{Name: "app-feat-claude", Path: dir, Options: map[string]string{tmux.OptionBranch: "feat"}},
+		tmuxtest.Session{Name: "app-claude-1", Path: dir},
+	)
+	SetCache("local", []WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: dir},
+	})
+	t.Cleanup(func() { SetCache("local", nil) })
+
+	groups, err := GroupSessions(f, "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byName := map[string]SessionInfo{}
+	for _, s := range groups[1].Sessions {
+		byName[s.Name] = s
+	}
+	if s := byName["app-feat-claude"]; s.Meta.Branch != "feat" || s.Branch != "main" {
+		t.Fatalf("expected recorded feat and current main, got %#v", s)
+	}
+	if s := byName["app-claude-1"]; s.Branch != "" {
+		t.Fatalf("did not expect a branch lookup for a counter-named session, got %q", s.Branch)
+	}
+}
+
+func TestCachedBranchRerunsGitOnlyWhenHeadChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	sub := filepath.Join(dir, "web")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := CachedBranch(sub); got != "main" {
+		t.Fatalf("expected main, got %q", got)
+	}
+
+	head := filepath.Join(dir, ".git", "HEAD")
+	branchCacheMu.Lock()
+	e := branchCache[head]
+	e.branch = "cached"
+	branchCache[head] = e
+	branchCacheMu.Unlock()
+	if got := CachedBranch(sub); got != "cached" {
+		t.Fatalf("expected the cached branch while HEAD is unchanged, got %q", got)
+	}
+
+	if out, err := exec.Command("git", "-C", dir, "symbolic-ref", "HEAD", "refs/heads/feat").CombinedOutput(); err != nil {
+		t.Fatalf("git symbolic-ref: %v %s", err, out)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(head, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := CachedBranch(sub); got != "feat" {
+		t.Fatalf("expected a fresh lookup after HEAD changed, got %q", got)
+	}
+}
@@ -0,0 +1,248 @@
+// Package match scores sessions and repos against the quick-attach tokens.
+package match
+
+import (
+	"sort"
+	"strings"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/session"
+)
+
+type Candidate struct {
+	Workspace string
+	Repo      string
+	Session   discovery.SessionInfo
+	Score     int
+}
+
+type CreatePlan struct {
+	Repo     string
+	Path     string
+	Template config.Template
+}
+
+// Candidates returns every session in groups matching tokens, best first.
+func Candidates(groups []discovery.WorkspaceGroup, tokens []string) []Candidate {
+	out := []Candidate{}
+	for _, g := range groups {
+		for _, s := range g.Sessions {
+			score, ok := Score(tokens, g, s)
+			if !ok {
+				continue
+			}
+			out = append(out, Candidate{
+				Workspace: discovery.WorkspaceName(g),
+				Repo:      g.Repo,
+				Session:   s,
+				Score:     score,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].Session.Name != out[j].Session.Name {
+			return out[i].Session.Name < out[j].Session.Name
+		}
+		if out[i].Workspace != out[j].Workspace {
+			return out[i].Workspace < out[j].Workspace
+		}
+		return out[i].Repo < out[j].Repo
+	})
+	return out
+}
+
+func PlanCreate(tokens []string, groups []discovery.WorkspaceGroup, templates []config.Template) (CreatePlan, bool) {
+	cleaned := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		tok = strings.TrimSpace(tok)
+		if tok != "" {
+			cleaned = append(cleaned, tok)
+		}
+	}
+	if len(cleaned) == 0 || len(templates) == 0 {
+		return CreatePlan{}, false
+	}
+
+	repoQuery := cleaned[0]
+	bestRepo := -1
+	bestRepoScore := 0
+	for i, g := range groups {
+		hay := Normalize(strings.Join([]string{g.Repo, g.Name, g.Workspace}, " "))
+		score, ok := ScoreHay(repoQuery, hay, true)
+		if !ok {
+			continue
+		}
+		if HasWordPrefix(Normalize(g.Repo), Normalize(repoQuery)) {
+			score += 10
+		}
+		if Normalize(g.Repo) == Normalize(repoQuery) {
+			score += 20
+		}
+		if score > bestRepoScore {
+			bestRepo = i
+			bestRepoScore = score
+		}
+	}
+	if bestRepo < 0 {
+		return CreatePlan{}, false
+	}
+
+	// The repo's .echoshell.toml may add templates and pick the default.
+	templates = groups[bestRepo].Config.Resolve(templates)
+	tpl := groups[bestRepo].Config.DefaultTemplate(templates)
+	if len(cleaned) > 1 {
+		tplQuery := strings.Join(cleaned[1:], " ")
+		bestTpl := -1
+		bestTplScore := 0
+		for i, t := range templates {
+			score, ok := ScoreHay(tplQuery, Normalize(t.Name+" "+t.Label), false)
+			if !ok {
+				continue
+			}
+			if Normalize(t.Name) == Normalize(tplQuery) {
+				score += 20
+			}
+			if score > bestTplScore {
+				bestTpl = i
+				bestTplScore = score
+			}
+		}
+		if bestTpl < 0 {
+			return CreatePlan{}, false
+		}
+		tpl = templates[bestTpl]
+	}
+
+	g := groups[bestRepo]
+	return CreatePlan{Repo: g.Repo, Path: g.Path, Template: tpl}, true
+}
+
+func Score(tokens []string, g discovery.WorkspaceGroup, s discovery.SessionInfo) (int, bool) {
+	cleaned := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		tok = strings.TrimSpace(tok)
+		if tok != "" {
+			cleaned = append(cleaned, tok)
+		}
+	}
+	if len(cleaned) == 0 {
+		return 0, false
+	}
+
+	if len(cleaned) >= 2 {
+		repoQuery := cleaned[0]
+		sessionQuery := strings.Join(cleaned[1:], " ")
+
+		repoHay := Normalize(strings.Join([]string{g.Repo, g.Name, g.Workspace}, " "))
+		sessionName := session.TrimRepoPrefix(g.Repo, s.Name)
+		sessionHay := Normalize(strings.Join([]string{s.Name, sessionName, s.Meta.Template}, " "))
+
+		repoScore, ok := ScoreHay(repoQuery, repoHay, true)
+		if !ok {
+			return 0, false
+		}
+		sessionScore, ok := ScoreHay(sessionQuery, sessionHay, false)
+		if !ok {
+			return 0, false
+		}
+
+		score := repoScore*3 + sessionScore*4
+		if HasWordPrefix(Normalize(g.Repo), Normalize(repoQuery)) {
+			score += 10
+		}
+		if HasWordPrefix(Normalize(sessionName), Normalize(sessionQuery)) {
+			score += 10
+		}
+		return score, true
+	}
+
+	hay := Normalize(strings.Join([]string{s.Name, g.Name, g.Workspace, g.Repo, s.Workdir, s.Meta.Template}, " "))
+	score, ok := ScoreHay(cleaned[0], hay, true)
+	if !ok {
+		return 0, false
+	}
+	if strings.Contains(hay, Normalize(s.Name)) {
+		score += 3
+	}
+	return score, true
+}
+
+func ScoreHay(query, hay string, allowSubseq bool) (int, bool) {
+	q := Normalize(query)
+	h := Normalize(hay)
+	if q == "" || h == "" {
+		return 0, false
+	}
+	parts := strings.Fields(q)
+	if len(parts) == 0 {
+		return 0, false
+	}
+	score := 0
+	for _, p := range parts {
+		if strings.Contains(h, p) {
+			score += 10 + len(p)
+			continue
+		}
+		if allowSubseq && subseq(h, p) {
+			score += 4 + len(p)
+			continue
+		}
+		return 0, false
+	}
+	return score, true
+}
+
+func HasWordPrefix(hay, query string) bool {
+	hayParts := strings.Fields(Normalize(hay))
+	queryParts := strings.Fields(Normalize(query))
+	if len(hayParts) == 0 || len(queryParts) == 0 {
+		return false
+	}
+	prefix := queryParts[0]
+	for _, hp := range hayParts {
+		if strings.HasPrefix(hp, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func Normalize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return ""
+	}
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func subseq(hay, needle string) bool {
+	if needle == "" {
+		return true
+	}
+	h := []rune(hay)
+	n := []rune(needle)
+	j := 0
+	for i := 0; i < len(h) && j < len(n); i++ {
+		if h[i] == n[j] {
+			j++
+		}
+	}
+	return j == len(n)
+}
@@ -0,0 +1,84 @@
+package match
+
+import (
+	"testing"
+
+	"echoshell/config"
+	"echoshell/discovery"
+)
+
+func TestScoreTwoArgsUseRepoThenSession(t *testing.T) {
+	g := discovery.WorkspaceGroup{Workspace: "git", Repo: "opasdf", Name: "git/opasdf"}
+	lazy := discovery.SessionInfo{Name: "opasdf-lazygit-1", Workdir: "/root/git/opasdf"}
+	shell := discovery.SessionInfo{Name: "opasdf-shell-1", Workdir: "/root/git/opasdf"}
+
+	if _, ok := Score([]string{"op", "la"}, g, lazy); !ok {
+		t.Fatalf("expected lazygit session to match repo+session query")
+	}
+	if _, ok := Score([]string{"op", "la"}, g, shell); ok {
+		t.Fatalf("did not expect shell session to match session query 'la'")
+	}
+}
+
+func TestScoreTwoArgsRequireRepoMatch(t *testing.T) {
+	g := discovery.WorkspaceGroup{Workspace: "git", Repo: "tools", Name: "git/tools"}
+	s := discovery.SessionInfo{Name: "tools-lazygit-1", Workdir: "/root/git/tools"}
+
+	if _, ok := Score([]string{"op", "la"}, g, s); ok {
+		t.Fatalf("did not expect repo mismatch to match query")
+	}
+}
+
+func TestPlanCreateUsesRepoAndTemplate(t *testing.T) {
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/root/git/app"},
+		{Workspace: "git", Repo: "tools", Name: "git/tools", Path: "/root/git/tools"},
+	}
+
+	plan, ok := PlanCreate([]string{"app", "claude"}, groups, config.DefaultTemplates())
+	if !ok {
+		t.Fatalf("expected create plan")
+	}
+	if plan.Repo != "app" || plan.Path != "/root/git/app" {
+		t.Fatalf("unexpected repo in plan: %#v", plan)
+	}
+	if plan.Template.Name != "claude" {
+		t.Fatalf("expected claude template, got %q", plan.Template.Name)
+	}
+}
+
+func TestPlanCreateRequiresTemplateMatch(t *testing.T) {
+	groups := []discovery.WorkspaceGroup{{Workspace: "git", Repo: "app", Name: "git/app", Path: "/root/git/app"}}
+
+	if _, ok := PlanCreate([]string{"app", "zzz"}, groups, config.DefaultTemplates()); ok {
+		t.Fatalf("did not expect plan for unknown template")
+	}
+	plan, ok := PlanCreate([]string{"app"}, groups, config.DefaultTemplates())
+	if !ok || plan.Template.Name != "shell" {
+		t.Fatalf("expected default shell template for repo-only query, got %#v", plan)
+	}
+}
+
+func TestPlanCreateUsesRepoConfig(t *testing.T) {
+	repo := config.Repo{Default: "dev", Templates: []config.Template{{Name: "dev", Command: "npm run dev"}}}
+	groups := []discovery.WorkspaceGroup{{Workspace: "git", Repo: "web", Name: "git/web", Path: "/root/git/web", Config: repo}}
+
+	plan, ok := PlanCreate([]string{"web"}, groups, config.DefaultTemplates())
+	if !ok || plan.Template.Command != "npm run dev" {
+		t.Fatalf("expected repo default template, got %#v", plan)
+	}
+	plan, ok = PlanCreate([]string{"web", "lazygit"}, groups, config.DefaultTemplates())
+	if !ok || plan.Template.Name != "lazygit" {
+		t.Fatalf("expected global templates to stay available, got %#v", plan)
+	}
+}
+
+func TestScoreMatchesRecordedTemplate(t *testing.T) {
+	g := discovery.WorkspaceGroup{Workspace: "git", Repo: "app", Name: "git/app"}
+	s := discovery.SessionInfo{Name: "app-auth-refactor", Meta: discovery.Meta{Template: "claude"}}
+
+	if _, ok := Score([]string{"app", "claude"}, g, s); !ok {
+		t.Fatalf("expected renamed session to match its template")
+	}
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"echoshell/config"
+	"echoshell/tmux"
+)
+
+// Naming is how Create names sessions.
+type Naming struct {
+	// Format has the placeholders {repo}, {command}, {branch}, {date} and
+	// {n}; see config.NameFormat.
+	Format string
+	// Reuse takes the lowest free {n} instead of one past the highest.
+	Reuse bool
+}
+
+// DefaultNaming is the naming configured in the environment.
+func DefaultNaming() Naming {
+	return Naming{Format: config.NameFormat(), Reuse: config.ReuseNumbers()}
+}
+
+// NameParts are the values substituted into a Naming format.
+type NameParts struct {
+	Repo    string
+	Command string
+	Branch  string
+	Date    time.Time
+}
+
+// Build returns the first name under n that no session on b uses and that is
+// not in taken. Without {n} in the format the bare name is tried first, then
+// -2, -3 and so on are appended.
+func (n Naming) Build(b tmux.Backend, parts NameParts, taken map[string]bool) (string, error) {
+	sessions, err := b.ListSessions()
+	if err != nil {
+		return "", err
+	}
+	used := map[string]bool{}
+	for name := range taken {
+		used[name] = true
+	}
+	for _, s := range sessions {
+		used[s.Name] = true
+	}
+
+	format := strings.TrimSpace(n.Format)
+	if format == "" {
+		format = config.DefaultNameFormat
+	}
+	start := 1
+	if !strings.Contains(format, "{n}") {
+		if name := renderName(format, parts); !used[name] {
+			return name, nil
+		}
+		format += "-{n}"
+		start = 2
+	}
+	pattern := renderName(format, parts)
+	if !n.Reuse {
+		for name := range used {
+			if num, ok := nameNumber(pattern, name); ok && num >= start {
+				start = num + 1
+			}
+		}
+	}
+	for i := start; ; i++ {
+		name := strings.ReplaceAll(pattern, "{n}", strconv.Itoa(i))
+		if !used[name] {
+			return name, nil
+		}
+	}
+}
+
+// renderName fills every placeholder but {n} with sanitized, truncated
+// values and tidies the dashes an empty value leaves behind.
+func renderName(format string, parts NameParts) string {
+	repo := truncate(Sanitize(parts.Repo), 24)
+	if repo == "" {
+		repo = "repo"
+	}
+	command := truncate(Sanitize(parts.Command), 12)
+	if command == "" {
+		command = "shell"
+	}
+	date := ""
+	if !parts.Date.IsZero() {
+		date = parts.Date.Format("20060102")
+	}
+	name := strings.NewReplacer(
+		"{repo}", repo,
+		"{command}", command,
+		"{branch}", truncate(Sanitize(parts.Branch), 24),
+		"{date}", date,
+		// tmux reserves these for targets.
+		":", "-",
+		".", "-",
+	).Replace(format)
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	return strings.Trim(name, "-")
+}
+
+// nameNumber extracts {n} from name if it was rendered from pattern.
+func nameNumber(pattern, name string) (int, bool) {
+	prefix, suffix, _ := strings.Cut(pattern, "{n}")
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return 0, false
+	}
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || rest[digits:] != strings.ReplaceAll(suffix, "{n}", rest[:digits]) {
+		return 0, false
+	}
+	num, err := strconv.Atoi(rest[:digits])
+	return num, err == nil
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"echoshell/config"
+	"echoshell/tmux"
+	"echoshell/tmux/tmuxtest"
+)
+
+func TestNamingCountsPastHighestOrReusesFreed(t *testing.T) {
+	f := tmuxtest.NewFake(
+		tmuxtest.Session{Name: "app-claude-1"},
+		tmuxtest.Session{Name: "app-claude-3"},
+		tmuxtest.Session{Name: "app-claude-full-7"},
+	)
+	parts := NameParts{Repo: "app", Command: "claude"}
+
+	name, err := Naming{Format: "{repo}-{command}-{n}"}.Build(f, parts, nil)
+	if err != nil || name != "app-claude-4" {
+		t.Fatalf("expected app-claude-4, got %q (%v)", name, err)
+	}
+	name, _ = Naming{Format: "{repo}-{command}-{n}", Reuse: true}.Build(f, parts, nil)
+	if name != "app-claude-2" {
+		t.Fatalf("expected freed app-claude-2, got %q", name)
+	}
+	name, _ = Naming{Format: "{repo}-{command}-{n}", Reuse: true}.Build(f, parts, map[string]bool{"app-claude-2": true})
+	if name != "app-claude-4" {
+		t.Fatalf("expected taken names to be skipped, got %q", name)
+	}
+}
+
+func TestNamingFormatPlaceholders(t *testing.T) {
+	f := tmuxtest.NewFake(tmuxtest.Session{Name: "app-feat-login-claude"})
+	date := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	name, _ := Naming{Format: "{date}.{repo}.{n}"}.Build(f, NameParts{Repo: "App", Date: date}, nil)
+	if name != "20260309-app-1" {
+		t.Fatalf("expected date format with dots replaced, got %q", name)
+	}
+	parts := NameParts{Repo: "app", Command: "claude", Branch: "feat/login"}
+	name, _ = Naming{Format: "{repo}-{branch}-{command}"}.Build(f, parts, nil)
+	if name != "app-feat-login-claude-2" {
+		t.Fatalf("expected a counter after a taken bare name, got %q", name)
+	}
+	parts.Branch = ""
+	name, _ = Naming{Format: "{repo}-{branch}-{command}"}.Build(f, parts, nil)
+	if name != "app-claude" {
+		t.Fatalf("expected empty branch to collapse, got %q", name)
+	}
+}
+
+// racingFake creates a session under the requested name just before the
+// first NewSession call, like a second echoshell winning the race.
+type racingFake struct {
+	*tmuxtest.Fake
+	raced bool
+}
+
+func (r *racingFake) NewSession(name, dir string, env map[string]string, command string) error {
+	if !r.raced {
+		r.raced = true
+		_ = r.Fake.NewSession(name, dir, nil, "")
+	}
+	return r.Fake.NewSession(name, dir, env, command)
+}
+
+func TestCreateRetriesNameTakenMeanwhile(t *testing.T) {
+	t.Setenv("ECHOSHELL_NAME_FORMAT", "")
+	f := &racingFake{Fake: tmuxtest.NewFake()}
+	name, err := Create(f, "/git/app", "app", config.Template{Name: "claude", Command: "claude"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "app-claude-2" {
+		t.Fatalf("expected retry to pick the next name, got %q", name)
+	}
+}
+
+func TestCreateNamesAfterBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", "-b", "feat/login", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	t.Setenv("ECHOSHELL_NAME_FORMAT", "branch")
+	f := tmuxtest.NewFake()
+	name, err := Create(f, dir, "app", config.Template{Name: "claude", Command: "claude"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "app-feat-login-claude" {
+		t.Fatalf("expected branch in name, got %q", name)
+	}
+	if s, _ := f.Session(name); s.Options[tmux.OptionBranch] != "feat/login" {
+		t.Fatalf("expected branch recorded, got %#v", s.Options)
+	}
+}
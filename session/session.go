@@ -0,0 +1,346 @@
+// Package session names, creates, renames and kills echoshell's tmux
+// sessions.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/tmux"
+)
+
+var ansiRE = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// Spec describes a session to start.
+type Spec struct {
+	Name string
+	// Dir is the start directory of the first pane.
+	Dir string
+	// RepoPath pins the session to a repo; it defaults to Dir.
+	RepoPath string
+	Template string
+	Command  string
+	// Resume continues the template's previous conversation.
+	Resume string
+	// Resumed types Resume instead of Command when set.
+	Resumed bool
+	// Env holds resolved values for the session environment; Pre are typed
+	// before the command.
+	Env map[string]string
+	Pre []string
+	// Exec runs the command as the first pane's process; see config.Template.
+	Exec bool
+	// Branch is the git branch the name was built from, if any.
+	Branch string
+}
+
+// createAttempts bounds how often Create retries a name someone else took.
+const createAttempts = 5
+
+// Create starts a session for repo at path with the next free name under
+// DefaultNaming and returns that name.
+func Create(b tmux.Backend, path, repo string, t config.Template) (string, error) {
+	env, err := t.Env.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	naming := DefaultNaming()
+	parts := NameParts{Repo: repo, Command: t.Name, Date: time.Now()}
+	if strings.Contains(naming.Format, "{branch}") {
+		parts.Branch = discovery.Branch(path)
+	}
+	// Another client may take the name between listing and creating; treat
+	// it as used and pick again.
+	taken := map[string]bool{}
+	for attempt := 1; ; attempt++ {
+		name, err := naming.Build(b, parts, taken)
+		if err != nil {
+			return "", err
+		}
+		err = New(b, Spec{Name: name, Dir: path, Template: t.Name, Command: t.Command, Resume: t.Resume, Env: env, Pre: t.Pre, Exec: t.Exec, Branch: parts.Branch})
+		if err == nil {
+			return name, nil
+		}
+		if !tmux.IsDuplicateSessionErr(err) || attempt == createAttempts {
+			return "", err
+		}
+		taken[name] = true
+	}
+}
+
+// New starts the session described by spec, records how it was made in its
+// @echoshell_* options and runs the pre-commands and command in its first
+// pane, typed into the shell or, for Exec, as the pane's process.
+func New(b tmux.Backend, spec Spec) error {
+	return start(b, spec, metaOptions(spec))
+}
+
+// start creates the session, sets opts on it and starts the command.
+func start(b tmux.Backend, spec Spec, opts [][2]string) error {
+	t := config.Template{Command: spec.Command, Resume: spec.Resume, Pre: spec.Pre, Exec: spec.Exec}
+	lines := t.Startup(spec.Resumed)
+	if err := b.NewSession(spec.Name, spec.Dir, spec.Env, startCommand(t, lines)); err != nil {
+		return err
+	}
+	// Pinning the repo keeps the session grouped wherever its panes wander.
+	// Everything still works from the start directory if this fails.
+	for _, opt := range opts {
+		_ = b.SetOption(spec.Name, opt[0], opt[1])
+	}
+	if t.Exec {
+		return nil
+	}
+	for _, line := range lines {
+		if err := b.SendKeys(spec.Name+":0.0", line, "C-m"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startCommand is the shell-command for the first pane of an Exec template:
+// lines run in sh, then the exit status is printed and the pane becomes an
+// interactive shell instead of closing. It is empty otherwise, leaving the
+// default shell.
+func startCommand(t config.Template, lines []string) string {
+	if !t.Exec || len(lines) == 0 {
+		return ""
+	}
+	script := strings.Join(lines, "\n") + "\n" +
+		`printf '\n[exited with status %d]\n' "$?"` + "\n" +
+		`exec "${SHELL:-/bin/sh}"`
+	return "sh -c " + tmux.ShellQuote(script)
+}
+
+func metaOptions(spec Spec) [][2]string {
+	repoPath := spec.RepoPath
+	if repoPath == "" {
+		repoPath = spec.Dir
+	}
+	opts := [][2]string{
+		{tmux.OptionRepo, repoPath},
+		{tmux.OptionTemplate, spec.Template},
+		{tmux.OptionCommand, spec.Command},
+	}
+	if spec.Resume != "" {
+		opts = append(opts, [2]string{tmux.OptionResume, spec.Resume})
+	}
+	if spec.Branch != "" {
+		opts = append(opts, [2]string{tmux.OptionBranch, spec.Branch})
+	}
+	if host, err := os.Hostname(); err == nil {
+		opts = append(opts, [2]string{tmux.OptionHost, host})
+	}
+	if u, err := user.Current(); err == nil {
+		opts = append(opts, [2]string{tmux.OptionCreator, u.Username})
+	}
+	return opts
+}
+
+func Sanitize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return ""
+	}
+	var b strings.Builder
+	lastDash := false
+	for _, r := range s {
+		ok := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		if ok {
+			b.WriteRune(r)
+			lastDash = false
+			continue
+		}
+		if !lastDash {
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+	out := strings.Trim(b.String(), "-")
+	return out
+}
+
+func TrimRepoPrefix(repo, session string) string {
+	r := strings.TrimSpace(repo)
+	s := strings.TrimSpace(session)
+	if r == "" || s == "" {
+		return session
+	}
+	prefix := r + "-"
+	if strings.HasPrefix(s, prefix) && len(s) > len(prefix) {
+		return s[len(prefix):]
+	}
+	return session
+}
+
+// RenamePrefix returns the repo prefix a renamed session must keep so
+// it still groups and displays under its repo. Sessions in the root fallback
+// group keep no prefix.
+func RenamePrefix(g discovery.WorkspaceGroup, name string) string {
+	repo := strings.TrimSpace(g.Repo)
+	if repo != "" && strings.HasPrefix(name, repo+"-") {
+		return repo + "-"
+	}
+	if strings.TrimSpace(g.Path) == "/" || strings.TrimSpace(g.Path) == "" {
+		return ""
+	}
+	token := Sanitize(repo)
+	if token == "" {
+		return ""
+	}
+	if len(token) > 24 {
+		token = token[:24]
+	}
+	return token + "-"
+}
+
+func RenamedName(prefix, input string) (string, error) {
+	token := Sanitize(input)
+	if prefix != "" {
+		token = strings.TrimPrefix(token, Sanitize(prefix)+"-")
+	}
+	if token == "" {
+		return "", errors.New("name must contain letters or digits")
+	}
+	return prefix + token, nil
+}
+
+// Kill destroys name unless it is the session echoshell runs in.
+func Kill(b tmux.Backend, target, name string) error {
+	if !config.IsLocalTarget(target) {
+		return b.KillSession(name)
+	}
+	if cur := tmux.CurrentSession(b); cur != "" && name == cur {
+		return errors.New("refusing to destroy current echoshell session")
+	}
+	return b.KillSession(name)
+}
+
+// SessionTemplate reconstructs the template s was started from: the recorded
+// template, command and resume command, else the template named by the
+// repo-template-N convention of the default naming format. A recorded template keeps the env
+// and pre-commands of the same-named entry in templates. ok is false when
+// neither applies.
+func SessionTemplate(repo string, s discovery.SessionInfo, templates []config.Template) (config.Template, bool) {
+	if s.Meta.Template != "" {
+		t := config.Template{Name: s.Meta.Template}
+		for _, known := range templates {
+			if known.Name == s.Meta.Template {
+				t = known
+				break
+			}
+		}
+		t.Command, t.Resume = s.Meta.Command, s.Meta.Resume
+		return t, true
+	}
+	return TemplateFromName(repo, s.Name, templates)
+}
+
+// TemplateFromName parses a name built with the default naming format back
+// into its template.
+func TemplateFromName(repo, name string, templates []config.Template) (config.Template, bool) {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return config.Template{}, false
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return config.Template{}, false
+	}
+	repoToken := truncate(Sanitize(repo), 24)
+	if repoToken == "" {
+		repoToken = "repo"
+	}
+	token, ok := strings.CutPrefix(name[:i], repoToken+"-")
+	if !ok {
+		return config.Template{}, false
+	}
+	for _, t := range templates {
+		if truncate(Sanitize(t.Name), 12) == token {
+			return t, true
+		}
+	}
+	return config.Template{}, false
+}
+
+// Restart respawns every pane of name in its current directory, keeping the
+// windows and layout, then starts t's resume command (or command) in the
+// first pane the same way New does.
+func Restart(b tmux.Backend, name string, t config.Template) error {
+	panes, err := b.ListPanes()
+	if err != nil {
+		return err
+	}
+	var first *tmux.Pane
+	for i := range panes {
+		p := &panes[i]
+		if p.Session != name {
+			continue
+		}
+		if first == nil || p.Window < first.Window || (p.Window == first.Window && p.Index < first.Index) {
+			first = p
+		}
+	}
+	if first == nil {
+		return fmt.Errorf("no panes in session %s", name)
+	}
+	lines := t.Startup(true)
+	for _, p := range panes {
+		if p.Session != name {
+			continue
+		}
+		command := ""
+		if p.ID == first.ID {
+			command = startCommand(t, lines)
+		}
+		if err := b.RespawnPane(p.ID, p.Path, command); err != nil {
+			return err
+		}
+	}
+	if t.Exec {
+		return nil
+	}
+	target := fmt.Sprintf("%s:%d.%d", name, first.Window, first.Index)
+	for _, line := range lines {
+		if err := b.SendKeys(target, line, "C-m"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Capture(b tmux.Backend, session string) (string, error) {
+	// capture-pane targets a pane; use the first pane of the first window by default.
+	// Use -J to join wrapped lines for cleaner rendering in this fixed preview area.
+	// Fallback to the session target for older tmux/edge cases.
+	pane := session + ":0.0"
+	out, err := b.CapturePane(pane)
+	if err != nil {
+		out2, err2 := b.CapturePane(session)
+		if err2 != nil {
+			return "", err
+		}
+		out = out2
+	}
+	return cleanPreview(out), nil
+}
+
+func cleanPreview(out string) string {
+	out = strings.ReplaceAll(out, "\r", "")
+	out = ansiRE.ReplaceAllString(out, "")
+	return strings.Trim(out, "\n")
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/tmux"
+	"echoshell/tmux/tmuxtest"
+)
+
+func TestRenamedNameKeepsRepoPrefix(t *testing.T) {
+	g := discovery.WorkspaceGroup{Workspace: "git", Repo: "app", Name: "git/app", Path: "/root/git/app"}
+	prefix := RenamePrefix(g, "app-claude-1")
+	if prefix != "app-" {
+		t.Fatalf("expected app- prefix, got %q", prefix)
+	}
+
+	name, err := RenamedName(prefix, "Claude Auth.Refactor")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "app-claude-auth-refactor" {
+		t.Fatalf("unexpected name %q", name)
+	}
+	if TrimRepoPrefix(g.Repo, name) != "claude-auth-refactor" {
+		t.Fatalf("renamed session should still trim repo prefix, got %q", TrimRepoPrefix(g.Repo, name))
+	}
+
+	name, err = RenamedName(prefix, "app-notes")
+	if err != nil || name != "app-notes" {
+		t.Fatalf("expected typed prefix not to be doubled, got %q (%v)", name, err)
+	}
+	if _, err := RenamedName(prefix, " :: "); err == nil {
+		t.Fatalf("expected empty name to be rejected")
+	}
+}
+
+func TestRenamePrefixRootHasNoPrefix(t *testing.T) {
+	g := discovery.WorkspaceGroup{Workspace: "root", Repo: "root", Name: "root", Path: "/"}
+	if prefix := RenamePrefix(g, "scratch"); prefix != "" {
+		t.Fatalf("expected no prefix for root sessions, got %q", prefix)
+	}
+}
+
+func TestKillRefusesCurrentSession(t *testing.T) {
+	f := tmuxtest.NewFake(tmuxtest.Session{Name: "picker", Path: "/"})
+	f.Current = "picker"
+	t.Setenv("TMUX_PANE", "%1")
+
+	if err := Kill(f, "local", "picker"); err == nil {
+		t.Fatalf("expected current session to be protected")
+	}
+	if len(f.Names()) != 1 {
+		t.Fatalf("session should not have been killed")
+	}
+}
+
+func TestCreateRecordsMetadata(t *testing.T) {
+	f := tmuxtest.NewFake()
+	name, err := Create(f, "/git/app", "app", config.Template{Name: "lazygit", Command: "lazygit"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, ok := f.Session(name)
+	if !ok || s.Options[tmux.OptionRepo] != "/git/app" {
+		t.Fatalf("expected %s to be pinned to /git/app, got %#v", name, s.Options)
+	}
+	if s.Options[tmux.OptionTemplate] != "lazygit" || s.Options[tmux.OptionCommand] != "lazygit" {
+		t.Fatalf("expected template metadata, got %#v", s.Options)
+	}
+	if s.Options[tmux.OptionHost] == "" || s.Options[tmux.OptionCreator] == "" {
+		t.Fatalf("expected host and creator metadata, got %#v", s.Options)
+	}
+}
+
+func TestCreateSetsEnvAndTypesPreCommands(t *testing.T) {
+	f := tmuxtest.NewFake()
+	repo := config.Repo{Env: config.Env{"RUST_LOG": config.Literal("debug"), "TOKEN": {Command: "printf 'secret\\n'"}}, Pre: []string{"nix develop"}}
+	tpl := config.Template{Name: "lazygit", Command: "lazygit"}
+	name, err := Create(f, t.TempDir(), "app", repo.DefaultTemplate(repo.Resolve([]config.Template{tpl})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, _ := f.Session(name)
+	if s.Env["RUST_LOG"] != "debug" || s.Env["TOKEN"] != "secret" {
+		t.Fatalf("expected resolved repo env on the session, got %#v", s.Env)
+	}
+	keys := f.Keys[name+":0.0"]
+	if len(keys) != 4 || keys[0] != "nix develop" || keys[2] != "lazygit" {
+		t.Fatalf("expected pre-command then command, got %v", keys)
+	}
+	if strings.Contains(strings.Join(keys, " "), "secret") {
+		t.Fatalf("secret was typed into the pane: %v", keys)
+	}
+}
+
+func TestCreateExecRunsCommandAsPaneProcess(t *testing.T) {
+	f := tmuxtest.NewFake()
+	name, err := Create(f, "/git/app", "app", config.Template{Name: "tests", Command: "go test ./...", Exec: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if started := f.Started[name]; !strings.HasPrefix(started, "sh -c ") || !strings.Contains(started, "go test ./...") {
+		t.Fatalf("expected wrapped command as the session's shell-command, got %q", started)
+	}
+	if len(f.Keys[name+":0.0"]) != 0 {
+		t.Fatalf("expected nothing typed in exec mode, got %v", f.Keys)
+	}
+
+	if err := Restart(f, name, config.Template{Command: "go test ./...", Exec: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.Respawned) != 1 || !strings.Contains(f.Started[f.Respawned[0]], "go test ./...") {
+		t.Fatalf("expected first pane respawned with the command, got %v %v", f.Respawned, f.Started)
+	}
+}
+
+func TestSessionTemplatePrefersMetadataThenName(t *testing.T) {
+	templates := config.DefaultTemplates()
+	restart := func(s discovery.SessionInfo) (string, bool) {
+		tpl, ok := SessionTemplate("app", s, templates)
+		return strings.Join(tpl.Startup(true), "; "), ok
+	}
+	s := discovery.SessionInfo{Name: "app-notes", Meta: discovery.Meta{Template: "lazygit", Command: "lazygit"}}
+	if cmd, ok := restart(s); !ok || cmd != "lazygit" {
+		t.Fatalf("expected recorded command, got %q (%v)", cmd, ok)
+	}
+	s = discovery.SessionInfo{Name: "app-notes", Meta: discovery.Meta{Template: "claude", Command: "claude", Resume: "claude --continue"}}
+	if cmd, ok := restart(s); !ok || cmd != "claude --continue" {
+		t.Fatalf("expected recorded resume command, got %q (%v)", cmd, ok)
+	}
+	s = discovery.SessionInfo{Name: "app-claude-full-3"}
+	if cmd, ok := restart(s); !ok || cmd != "claude --dangerously-skip-permissions --continue" {
+		t.Fatalf("expected claude-full resume command from name, got %q (%v)", cmd, ok)
+	}
+	if _, ok := restart(discovery.SessionInfo{Name: "scratch"}); ok {
+		t.Fatalf("did not expect a command for a foreign session")
+	}
+	templates = config.Repo{Pre: []string{"nix develop"}}.Resolve(templates)
+	s = discovery.SessionInfo{Name: "app-notes", Meta: discovery.Meta{Template: "lazygit", Command: "lazygit"}}
+	if cmd, _ := restart(s); cmd != "nix develop; lazygit" {
+		t.Fatalf("expected repo pre-command before recorded command, got %q", cmd)
+	}
+}
+
+func TestRestartRespawnsEveryPane(t *testing.T) {
+	f := tmuxtest.NewFake(tmuxtest.Session{Name: "app-claude-1", Path: "/git/app", Panes: []string{"/git/app/web"}})
+	if err := Restart(f, "app-claude-1", config.Template{Command: "claude"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.Respawned) != 2 {
+		t.Fatalf("expected both panes respawned, got %v", f.Respawned)
+	}
+	if keys := f.Keys["app-claude-1:0.0"]; len(keys) != 2 || keys[0] != "claude" {
+		t.Fatalf("expected command typed into first pane, got %v", f.Keys)
+	}
+}
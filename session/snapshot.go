@@ -0,0 +1,214 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/state"
+	"echoshell/tmux"
+)
+
+// Snapshot records the grouped sessions with their windows, layouts, pane
+// directories and the template each was started from.
+func Snapshot(b tmux.Backend, groups []discovery.WorkspaceGroup, templates []config.Template) (state.Snapshot, error) {
+	windows, err := b.ListWindows()
+	if err != nil {
+		return state.Snapshot{}, err
+	}
+	panes, err := b.ListPanes()
+	if err != nil {
+		return state.Snapshot{}, err
+	}
+	sort.SliceStable(panes, func(i, j int) bool {
+		if panes[i].Window != panes[j].Window {
+			return panes[i].Window < panes[j].Window
+		}
+		return panes[i].Index < panes[j].Index
+	})
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].Index < windows[j].Index })
+
+	snap := state.Snapshot{Version: state.SnapshotVersion, Saved: time.Now().Unix(), Sessions: []state.SavedSession{}}
+	for _, g := range groups {
+		for _, s := range g.Sessions {
+			saved := state.SavedSession{Name: s.Name, Repo: g.Repo, RepoPath: s.Meta.RepoPath, Options: savedOptions(s.Meta)}
+			if saved.RepoPath == "" && g.Path != "/" {
+				saved.RepoPath = g.Path
+			}
+			if t, ok := SessionTemplate(g.Repo, s, g.Config.Resolve(templates)); ok {
+				saved.Template, saved.Command, saved.Resume = t.Name, t.Command, t.Resume
+			}
+			for _, w := range windows {
+				if w.Session != s.Name {
+					continue
+				}
+				sw := state.SavedWindow{Name: w.Name, Layout: w.Layout}
+				for _, p := range panes {
+					if p.Session == s.Name && p.Window == w.Index {
+						sw.Panes = append(sw.Panes, p.Path)
+					}
+				}
+				saved.Windows = append(saved.Windows, sw)
+			}
+			snap.Sessions = append(snap.Sessions, saved)
+		}
+	}
+	return snap, nil
+}
+
+// Save snapshots the grouped sessions with the built-in templates and writes
+// the snapshot to the state dir, returning how many sessions it holds and
+// where it went. `echoshell save` and the picker's export share it.
+func Save(b tmux.Backend, groups []discovery.WorkspaceGroup) (int, string, error) {
+	snap, err := Snapshot(b, groups, config.DefaultTemplates())
+	if err != nil {
+		return 0, "", err
+	}
+	path, err := state.SaveSnapshot(snap)
+	if err != nil {
+		return 0, "", err
+	}
+	return len(snap.Sessions), path, nil
+}
+
+// Restore recreates the snapshot's sessions under their saved names with the
+// @echoshell_* options they had, then rebuilds their windows and layouts.
+// Env and pre-commands come from the saved template as resolved by the
+// repo's .echoshell.toml today. Sessions that already exist are skipped.
+func Restore(b tmux.Backend, snap state.Snapshot, templates []config.Template) (restored, skipped []string, err error) {
+	existing, err := b.ListSessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	alive := map[string]bool{}
+	for _, s := range existing {
+		alive[s.Name] = true
+	}
+	for _, ss := range snap.Sessions {
+		if alive[ss.Name] {
+			skipped = append(skipped, ss.Name)
+			continue
+		}
+		if err := restoreSession(b, ss, templates); err != nil {
+			return restored, skipped, err
+		}
+		restored = append(restored, ss.Name)
+	}
+	return restored, skipped, nil
+}
+
+func restoreSession(b tmux.Backend, ss state.SavedSession, templates []config.Template) error {
+	windows := ss.Windows
+	if len(windows) == 0 {
+		windows = []state.SavedWindow{{Panes: []string{ss.RepoPath}}}
+	}
+	spec := Spec{
+		Name:     ss.Name,
+		Dir:      firstPanePath(windows[0], ss.RepoPath),
+		RepoPath: ss.RepoPath,
+		Template: ss.Template,
+		Command:  ss.Command,
+		Resume:   ss.Resume,
+		Resumed:  true,
+	}
+	if ss.Template != "" {
+		var repo config.Repo
+		if ss.RepoPath != "" {
+			// A broken .echoshell.toml should not stop the restore.
+			repo, _ = config.LoadRepo(ss.RepoPath)
+		}
+		for _, t := range repo.Resolve(templates) {
+			if t.Name != ss.Template {
+				continue
+			}
+			env, err := t.Env.Resolve(ss.RepoPath)
+			if err != nil {
+				return fmt.Errorf("%s: %w", ss.Name, err)
+			}
+			spec.Env, spec.Pre, spec.Exec = env, t.Pre, t.Exec
+			break
+		}
+	}
+	if err := start(b, spec, restoredOptions(ss.Options)); err != nil {
+		return err
+	}
+	if err := restoreWindow(b, ss.Name, windows[0]); err != nil {
+		return err
+	}
+	for _, w := range windows[1:] {
+		target, err := b.NewWindow(ss.Name, w.Name, firstPanePath(w, ss.RepoPath))
+		if err != nil {
+			return err
+		}
+		if err := restoreWindow(b, target, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savedOptions are the @echoshell_* options behind meta; none for a session
+// echoshell did not create.
+func savedOptions(meta discovery.Meta) map[string]string {
+	opts := map[string]string{}
+	for opt, v := range map[string]string{
+		tmux.OptionRepo:     meta.RepoPath,
+		tmux.OptionTemplate: meta.Template,
+		tmux.OptionCommand:  meta.Command,
+		tmux.OptionResume:   meta.Resume,
+		tmux.OptionBranch:   meta.Branch,
+		tmux.OptionHost:     meta.Host,
+		tmux.OptionCreator:  meta.Creator,
+	} {
+		if v != "" {
+			opts[opt] = v
+		}
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
+
+// restoredOptions orders saved options like tmux.SessionOptions and drops
+// any that are not echoshell's.
+func restoredOptions(saved map[string]string) [][2]string {
+	opts := [][2]string{}
+	for _, opt := range tmux.SessionOptions {
+		if v, ok := saved[opt]; ok {
+			opts = append(opts, [2]string{opt, v})
+		}
+	}
+	return opts
+}
+
+// restoreWindow adds the panes after the first to target and reapplies the
+// saved layout.
+func restoreWindow(b tmux.Backend, target string, w state.SavedWindow) error {
+	if len(w.Panes) < 2 {
+		return nil
+	}
+	for _, dir := range w.Panes[1:] {
+		if err := b.SplitPane(target, dir); err != nil {
+			return err
+		}
+		// Retile so a small detached window keeps room for the next split.
+		_ = b.SelectLayout(target, "tiled")
+	}
+	if w.Layout == "" {
+		return nil
+	}
+	// A saved layout can fail to apply when the window is smaller than it was;
+	// the panes are there regardless, so keep the tiled fallback then.
+	_ = b.SelectLayout(target, w.Layout)
+	return nil
+}
+
+func firstPanePath(w state.SavedWindow, fallback string) string {
+	if len(w.Panes) > 0 && w.Panes[0] != "" {
+		return w.Panes[0]
+	}
+	return fallback
+}
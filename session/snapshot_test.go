@@ -0,0 +1,135 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/state"
+	"echoshell/tmux/tmuxtest"
+)
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	src := tmuxtest.NewFake(
+		tmuxtest.Session{
+			Name:    "app-claude-2",
+			Path:    "/git/app",
+			Panes:   []string{"/git/app/web"},
+			More:    [][]string{{"/git/app/api", "/tmp"}},
+			Layouts: map[int]string{0: "even-horizontal", 1: "main-vertical"},
+		},
+	)
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app", Sessions: []discovery.SessionInfo{{Name: "app-claude-2"}}},
+	}
+	snap, err := Snapshot(src, groups, config.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(snap.Sessions) != 1 || snap.Sessions[0].Template != "claude" || snap.Sessions[0].RepoPath != "/git/app" {
+		t.Fatalf("unexpected snapshot: %#v", snap)
+	}
+
+	dst := tmuxtest.NewFake(tmuxtest.Session{Name: "other", Path: "/"})
+	restored, skipped, err := Restore(dst, snap, config.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(restored, []string{"app-claude-2"}) || len(skipped) != 0 {
+		t.Fatalf("restored %v, skipped %v", restored, skipped)
+	}
+	got, _ := dst.Session("app-claude-2")
+	want, _ := src.Session("app-claude-2")
+	if got.Path != want.Path || !reflect.DeepEqual(got.Panes, want.Panes) || !reflect.DeepEqual(got.More, want.More) || !reflect.DeepEqual(got.Layouts, want.Layouts) {
+		t.Fatalf("restored session differs:\n got %#v\nwant %#v", got, want)
+	}
+	if len(got.Options) != 0 {
+		t.Fatalf("expected no metadata on a session echoshell did not create, got %#v", got.Options)
+	}
+	if keys := dst.Keys["app-claude-2:0.0"]; len(keys) == 0 || keys[0] != "claude --continue" {
+		t.Fatalf("expected restored claude session to resume, got %v", dst.Keys)
+	}
+
+	if _, skipped, _ := Restore(dst, snap, config.DefaultTemplates()); !reflect.DeepEqual(skipped, []string{"app-claude-2"}) {
+		t.Fatalf("expected running session to be skipped, got %v", skipped)
+	}
+}
+
+func TestRestoreSetsOnlyRecordedOptions(t *testing.T) {
+	meta := discovery.Meta{Template: "claude", Command: "claude", RepoPath: "/git/app", Creator: "alice"}
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app", Sessions: []discovery.SessionInfo{{Name: "app-claude-1", Meta: meta}}},
+	}
+	src := tmuxtest.NewFake(tmuxtest.Session{Name: "app-claude-1", Path: "/git/app"})
+	snap, err := Snapshot(src, groups, config.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dst := tmuxtest.NewFake()
+	if _, _, err := Restore(dst, snap, config.DefaultTemplates()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := dst.Session("app-claude-1")
+	want := map[string]string{
+		"@echoshell_template": "claude",
+		"@echoshell_command":  "claude",
+		"@echoshell_repo":     "/git/app",
+		"@echoshell_creator":  "alice",
+	}
+	if !reflect.DeepEqual(got.Options, want) {
+		t.Fatalf("expected only the recorded options, got %#v", got.Options)
+	}
+}
+
+func TestSaveWritesSnapshotToStateDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	f := tmuxtest.NewFake(tmuxtest.Session{Name: "app-shell-1", Path: "/git/app"})
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}}},
+	}
+	n, path, err := Save(f, groups)
+	if err != nil || n != 1 {
+		t.Fatalf("expected one saved session, got %d %v", n, err)
+	}
+	snap, err := state.LoadSnapshot()
+	if err != nil || len(snap.Sessions) != 1 || snap.Sessions[0].Name != "app-shell-1" {
+		t.Fatalf("expected %s to hold the session, got %#v %v", path, snap, err)
+	}
+}
+
+func TestSaveKeepsTheCallersOwnSession(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TMUX_PANE", "%99")
+	f := tmuxtest.NewFake(
+		tmuxtest.Session{Name: "app-shell-1", Path: "/git/app"},
+		tmuxtest.Session{Name: "app-claude-1", Path: "/git/app"},
+	)
+	f.Current = "app-claude-1"
+	discovery.SetCache("local", []discovery.WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app"},
+	})
+	t.Cleanup(func() { discovery.SetCache("local", nil) })
+
+	groups, err := discovery.GroupAllSessions(f, "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, _, err := Save(f, groups); err != nil || n != 2 {
+		t.Fatalf("expected both sessions saved, got %d %v", n, err)
+	}
+	snap, err := state.LoadSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	saved := map[string]bool{}
+	for _, s := range snap.Sessions {
+		saved[s.Name] = true
+	}
+	if !saved["app-claude-1"] {
+		t.Fatalf("expected the current session in the snapshot, got %#v", snap.Sessions)
+	}
+}
@@ -0,0 +1,304 @@
+// Package state persists what echoshell remembers between runs: recent
+// targets, the last selected workspace per target and session snapshots.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"echoshell/config"
+)
+
+func ResolveTarget() string {
+	env := strings.TrimSpace(os.Getenv("ECHOSHELL_REMOTE"))
+	if env != "" {
+		return env
+	}
+	if saved, _ := LastTarget(); strings.TrimSpace(saved) != "" {
+		return saved
+	}
+	return config.DefaultTarget
+}
+
+func targetsPath() (string, error) {
+	d, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "targets.txt"), nil
+}
+
+func Targets() ([]string, error) {
+	path, err := targetsPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return []string{"local"}, nil
+	}
+
+	targets := []string{}
+	seen := make(map[string]bool)
+	for _, ln := range strings.Split(string(raw), "\n") {
+		v := strings.TrimSpace(ln)
+		if v != "" && !seen[v] {
+			targets = append(targets, v)
+			seen[v] = true
+		}
+	}
+
+	// Always ensure "local" is in the list
+	if !seen["local"] {
+		targets = append(targets, "local")
+	}
+
+	return targets, nil
+}
+
+func LastTarget() (string, error) {
+	path, err := targetsPath()
+	if err != nil {
+		return "", err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, ln := range strings.Split(string(raw), "\n") {
+		v := strings.TrimSpace(ln)
+		if v != "" {
+			return v, nil
+		}
+	}
+	return "", nil
+}
+
+func RememberTarget(target string) error {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil
+	}
+	path, err := targetsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	current := []string{}
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		for _, ln := range strings.Split(string(raw), "\n") {
+			v := strings.TrimSpace(ln)
+			if v != "" && v != target {
+				current = append(current, v)
+			}
+		}
+	}
+	next := append([]string{target}, current...)
+	if len(next) > 20 {
+		next = next[:20]
+	}
+	return os.WriteFile(path, []byte(strings.Join(next, "\n")+"\n"), 0o644)
+}
+
+func workspacesPath() (string, error) {
+	d, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "workspaces.txt"), nil
+}
+
+func LastWorkspace(target string) (string, error) {
+	target = config.NormalizeTarget(target)
+	path, err := workspacesPath()
+	if err != nil {
+		return "", err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, ln := range strings.Split(string(raw), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(ln), "|", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if config.NormalizeTarget(parts[0]) == target {
+			return strings.TrimSpace(parts[1]), nil
+		}
+	}
+	return "", nil
+}
+
+func RememberWorkspace(target, workspace string) error {
+	target = config.NormalizeTarget(target)
+	workspace = strings.TrimSpace(workspace)
+	if target == "" || workspace == "" {
+		return nil
+	}
+	path, err := workspacesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	rows := []string{target + "|" + workspace}
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		for _, ln := range strings.Split(string(raw), "\n") {
+			parts := strings.SplitN(strings.TrimSpace(ln), "|", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			t := config.NormalizeTarget(parts[0])
+			w := strings.TrimSpace(parts[1])
+			if t == "" || w == "" || t == target {
+				continue
+			}
+			rows = append(rows, t+"|"+w)
+		}
+	}
+	if len(rows) > 20 {
+		rows = rows[:20]
+	}
+	return os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0o644)
+}
+
+// SnapshotVersion is bumped when Snapshot changes incompatibly.
+const SnapshotVersion = 1
+
+// Snapshot is everything needed to recreate the sessions of a tmux server.
+type Snapshot struct {
+	Version  int            `json:"version"`
+	Saved    int64          `json:"saved"`
+	Sessions []SavedSession `json:"sessions"`
+}
+
+type SavedSession struct {
+	Name     string `json:"name"`
+	Repo     string `json:"repo"`
+	RepoPath string `json:"repo_path"`
+	Template string `json:"template"`
+	Command  string `json:"command"`
+	Resume   string `json:"resume,omitempty"`
+	// Options are the @echoshell_* options the session had. Restore sets
+	// only these, so sessions echoshell did not create stay unpinned.
+	Options map[string]string `json:"options,omitempty"`
+	Windows []SavedWindow     `json:"windows"`
+}
+
+type SavedWindow struct {
+	Name   string `json:"name"`
+	Layout string `json:"layout"`
+	// Panes are the working directories of the window's panes in order.
+	Panes []string `json:"panes"`
+}
+
+func SnapshotPath() (string, error) {
+	d, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "sessions.json"), nil
+}
+
+func SaveSnapshot(snap Snapshot) (string, error) {
+	path, err := SnapshotPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return path, writeJSON(path, snap)
+}
+
+// writeJSON writes then renames so a crash mid-save keeps the previous file.
+func writeJSON(path string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(raw, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadSnapshot returns the saved snapshot; a missing file is an empty one.
+func LoadSnapshot() (Snapshot, error) {
+	path, err := SnapshotPath()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Snapshot{Version: SnapshotVersion}, nil
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return Snapshot{}, err
+	}
+	if snap.Version > SnapshotVersion {
+		return Snapshot{}, fmt.Errorf("%s has version %d; this echoshell reads up to %d", path, snap.Version, SnapshotVersion)
+	}
+	return snap, nil
+}
+
+// View is how the picker's repo list was last arranged. Repos are named by
+// their group name, e.g. git/app.
+type View struct {
+	Collapsed []string `json:"collapsed,omitempty"`
+	Pinned    []string `json:"pinned,omitempty"`
+	HideEmpty bool     `json:"hide_empty,omitempty"`
+}
+
+func viewPath() (string, error) {
+	d, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "view.json"), nil
+}
+
+// LoadView returns the saved view; a missing file is an empty one.
+func LoadView() (View, error) {
+	path, err := viewPath()
+	if err != nil {
+		return View{}, err
+	}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return View{}, nil
+	}
+	if err != nil {
+		return View{}, err
+	}
+	var v View
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return View{}, err
+	}
+	return v, nil
+}
+
+func SaveView(v View) error {
+	path, err := viewPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeJSON(path, v)
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	empty, err := LoadSnapshot()
+	if err != nil || len(empty.Sessions) != 0 {
+		t.Fatalf("expected empty snapshot without a file, got %#v (%v)", empty, err)
+	}
+
+	snap := Snapshot{Version: SnapshotVersion, Saved: 1, Sessions: []SavedSession{{
+		Name:     "app-shell-1",
+		Repo:     "app",
+		RepoPath: "/git/app",
+		Template: "shell",
+		Windows:  []SavedWindow{{Layout: "tiled", Panes: []string{"/git/app", "/tmp"}}},
+	}}}
+	if _, err := SaveSnapshot(snap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := LoadSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, snap) {
+		t.Fatalf("got %#v, want %#v", got, snap)
+	}
+}
+
+func TestViewRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if v, err := LoadView(); err != nil || !reflect.DeepEqual(v, View{}) {
+		t.Fatalf("expected empty view without a file, got %#v (%v)", v, err)
+	}
+	want := View{Collapsed: []string{"git/app"}, Pinned: []string{"git/tools"}, HideEmpty: true}
+	if err := SaveView(want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := LoadView(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v (%v), want %#v", got, err, want)
+	}
+}
@@ -0,0 +1,350 @@
+package tmux
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"echoshell/config"
+)
+
+// Backend is every tmux operation echoshell performs on the target
+// machine. The TUI and CLI hold one of these so tests can swap in a fake
+// (see tmuxtest).
+type Backend interface {
+	ListSessions() ([]Session, error)
+	ListPanes() ([]Pane, error)
+	ListWindows() ([]Window, error)
+	NewSession(name, dir string, env map[string]string, command string) error
+	SendKeys(target string, keys ...string) error
+	KillSession(name string) error
+	RenameSession(name, newName string) error
+	SetOption(target, option, value string) error
+	CapturePane(target string) (string, error)
+	SplitWindow(target string, percent int, command string) (string, error)
+	NewWindow(session, name, dir string) (string, error)
+	SplitPane(target, dir string) error
+	SelectLayout(target, layout string) error
+	RespawnPane(pane, dir, command string) error
+	KillPane(pane string) error
+	SelectPane(pane string) error
+	Display(target, format string) (string, error)
+}
+
+type Session struct {
+	Name     string
+	Attached bool
+	Windows  int
+	Activity int64
+	// Path is the directory the session was started in (session_path).
+	Path string
+	// Options holds the @echoshell_* user options that are set.
+	Options map[string]string
+}
+
+type Window struct {
+	Session string
+	Index   int
+	Name    string
+	Layout  string
+}
+
+// User options echoshell stores on the sessions it creates.
+const (
+	OptionRepo     = "@echoshell_repo"
+	OptionTemplate = "@echoshell_template"
+	OptionCommand  = "@echoshell_command"
+	OptionResume   = "@echoshell_resume"
+	OptionBranch   = "@echoshell_branch"
+	OptionHost     = "@echoshell_host"
+	OptionCreator  = "@echoshell_creator"
+)
+
+// SessionOptions are the user options ListSessions reads back.
+var SessionOptions = []string{OptionRepo, OptionTemplate, OptionCommand, OptionResume, OptionBranch, OptionHost, OptionCreator}
+
+type Pane struct {
+	ID      string
+	Session string
+	Window  int
+	Index   int
+	Command string
+	Path    string
+}
+
+// cliBackend drives the tmux binary through run, which is either a local
+// exec (optionally over the control-mode client) or ssh.
+type cliBackend struct {
+	run func(args ...string) (string, error)
+}
+
+func Local() Backend {
+	return cliBackend{run: Run}
+}
+
+func SSH(target string) Backend {
+	return cliBackend{run: func(args ...string) (string, error) {
+		return RunSSH(target, "tmux "+ShellJoin(args))
+	}}
+}
+
+func ForTarget(target string) Backend {
+	if config.NormalizeTarget(target) == "local" {
+		return Local()
+	}
+	return SSH(target)
+}
+
+func IsNoServerErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "no server running") || strings.Contains(msg, "failed to connect") || strings.Contains(msg, "error connecting to")
+}
+
+// IsDuplicateSessionErr reports whether new-session failed because the name
+// is taken.
+func IsDuplicateSessionErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "duplicate session")
+}
+
+func (b cliBackend) ListSessions() ([]Session, error) {
+	// Free-form fields are escaped with #{q:} so a '|' inside an option value
+	// or path can't shift the other fields.
+	fields := []string{"#{q:session_name}", "#{session_attached}", "#{session_windows}", "#{session_activity}", "#{q:session_path}"}
+	for _, opt := range SessionOptions {
+		fields = append(fields, "#{q:"+opt+"}")
+	}
+	out, err := b.run("list-sessions", "-F", strings.Join(fields, "|"))
+	if err != nil {
+		if IsNoServerErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sessions := []Session{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := splitEscaped(strings.TrimSpace(line), '|')
+		if len(parts) != len(fields) {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			continue
+		}
+		options := map[string]string{}
+		for i, opt := range SessionOptions {
+			if v := parts[5+i]; v != "" {
+				options[opt] = v
+			}
+		}
+		sessions = append(sessions, Session{
+			Name:     name,
+			Attached: strings.TrimSpace(parts[1]) == "1",
+			Windows:  atoiSafe(strings.TrimSpace(parts[2])),
+			Activity: int64(atoiSafe(strings.TrimSpace(parts[3]))),
+			Path:     strings.TrimSpace(parts[4]),
+			Options:  options,
+		})
+	}
+	return sessions, nil
+}
+
+// splitEscaped splits a line of #{q:} escaped fields on unescaped sep and
+// removes the escaping.
+func splitEscaped(line string, sep byte) []string {
+	parts := []string{}
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			cur.WriteByte(line[i])
+		case c == sep:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+func (b cliBackend) ListPanes() ([]Pane, error) {
+	// The path goes last so a '|' inside it can't shift the other fields.
+	out, err := b.run("list-panes", "-a", "-F", "#{session_name}|#{window_index}|#{pane_index}|#{pane_id}|#{pane_current_command}|#{pane_current_path}")
+	if err != nil {
+		if IsNoServerErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	panes := []Pane{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, "|", 6)
+		if len(parts) != 6 {
+			continue
+		}
+		panes = append(panes, Pane{
+			Session: strings.TrimSpace(parts[0]),
+			Window:  atoiSafe(strings.TrimSpace(parts[1])),
+			Index:   atoiSafe(strings.TrimSpace(parts[2])),
+			ID:      strings.TrimSpace(parts[3]),
+			Command: strings.TrimSpace(parts[4]),
+			Path:    strings.TrimSpace(parts[5]),
+		})
+	}
+	return panes, nil
+}
+
+func (b cliBackend) ListWindows() ([]Window, error) {
+	out, err := b.run("list-windows", "-a", "-F", "#{q:session_name}|#{window_index}|#{window_layout}|#{q:window_name}")
+	if err != nil {
+		if IsNoServerErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	windows := []Window{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := splitEscaped(line, '|')
+		if len(parts) != 4 {
+			continue
+		}
+		windows = append(windows, Window{
+			Session: parts[0],
+			Index:   atoiSafe(parts[1]),
+			Layout:  parts[2],
+			Name:    parts[3],
+		})
+	}
+	return windows, nil
+}
+
+// NewSession passes env with -e so it lands in the session environment
+// without being typed into the pane. command, when set, replaces the default
+// shell as the first pane's process.
+func (b cliBackend) NewSession(name, dir string, env map[string]string, command string) error {
+	args := []string{"new-session", "-d", "-s", name}
+	if strings.TrimSpace(dir) != "" {
+		args = append(args, "-c", dir)
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-e", k+"="+env[k])
+	}
+	if strings.TrimSpace(command) != "" {
+		args = append(args, command)
+	}
+	_, err := b.run(args...)
+	return err
+}
+
+func (b cliBackend) SendKeys(target string, keys ...string) error {
+	_, err := b.run(append([]string{"send-keys", "-t", target}, keys...)...)
+	return err
+}
+
+func (b cliBackend) KillSession(name string) error {
+	_, err := b.run("kill-session", "-t", name)
+	return err
+}
+
+func (b cliBackend) RenameSession(name, newName string) error {
+	_, err := b.run("rename-session", "-t", name, newName)
+	return err
+}
+
+func (b cliBackend) SetOption(target, option, value string) error {
+	_, err := b.run("set-option", "-t", target, option, value)
+	return err
+}
+
+func (b cliBackend) CapturePane(target string) (string, error) {
+	return b.run("capture-pane", "-p", "-J", "-t", target)
+}
+
+func (b cliBackend) SplitWindow(target string, percent int, command string) (string, error) {
+	args := []string{"split-window", "-h", "-p", fmt.Sprintf("%d", percent), "-d", "-P", "-F", "#{pane_id}"}
+	if strings.TrimSpace(target) != "" {
+		args = append(args, "-t", target)
+	}
+	args = append(args, command)
+	out, err := b.run(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// NewWindow appends a window to session without selecting it and returns
+// its id, usable as a target.
+func (b cliBackend) NewWindow(session, name, dir string) (string, error) {
+	args := []string{"new-window", "-d", "-P", "-F", "#{window_id}", "-t", session + ":"}
+	if strings.TrimSpace(name) != "" {
+		args = append(args, "-n", name)
+	}
+	if strings.TrimSpace(dir) != "" {
+		args = append(args, "-c", dir)
+	}
+	out, err := b.run(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (b cliBackend) SplitPane(target, dir string) error {
+	args := []string{"split-window", "-d", "-t", target}
+	if strings.TrimSpace(dir) != "" {
+		args = append(args, "-c", dir)
+	}
+	_, err := b.run(args...)
+	return err
+}
+
+func (b cliBackend) SelectLayout(target, layout string) error {
+	_, err := b.run("select-layout", "-t", target, layout)
+	return err
+}
+
+// RespawnPane kills whatever runs in pane and starts command (or the default
+// shell when empty) in dir (or the pane's start directory when empty).
+func (b cliBackend) RespawnPane(pane, dir, command string) error {
+	args := []string{"respawn-pane", "-k", "-t", pane}
+	if strings.TrimSpace(dir) != "" {
+		args = append(args, "-c", dir)
+	}
+	if strings.TrimSpace(command) != "" {
+		args = append(args, command)
+	}
+	_, err := b.run(args...)
+	return err
+}
+
+func (b cliBackend) KillPane(pane string) error {
+	_, err := b.run("kill-pane", "-t", pane)
+	return err
+}
+
+func (b cliBackend) SelectPane(pane string) error {
+	_, err := b.run("select-pane", "-t", pane)
+	return err
+}
+
+func (b cliBackend) Display(target, format string) (string, error) {
+	args := []string{"display-message", "-p"}
+	if strings.TrimSpace(target) != "" {
+		args = append(args, "-t", target)
+	}
+	out, err := b.run(append(args, format)...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
@@ -0,0 +1,286 @@
+package tmux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const controlCommandTimeout = 8 * time.Second
+
+var errControlClosed = errors.New("tmux control client closed")
+
+var tmuxControl *ControlClient
+
+var tmuxControlMu sync.RWMutex
+
+type controlReply struct {
+	out string
+	err error
+}
+
+// ControlClient keeps one `tmux -C` connection open. Commands are written as
+// lines and answered in order by %begin/%end blocks; everything else starting
+// with % is a notification.
+type ControlClient struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	mu      sync.Mutex
+	pending []chan controlReply
+	events  chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+// controlNotifications are the notifications that change what the picker
+// shows.
+var controlNotifications = map[string]bool{
+	"%sessions-changed":      true,
+	"%session-renamed":       true,
+	"%window-add":            true,
+	"%window-close":          true,
+	"%unlinked-window-add":   true,
+	"%unlinked-window-close": true,
+	"%window-renamed":        true,
+	"%layout-change":         true,
+}
+
+func StartControl(session string) error {
+	session = strings.TrimSpace(session)
+	if session == "" {
+		return errors.New("no tmux session to attach control client to")
+	}
+	c, err := dialTmuxControl(session)
+	if err != nil {
+		return err
+	}
+	tmuxControlMu.Lock()
+	tmuxControl = c
+	tmuxControlMu.Unlock()
+	return nil
+}
+
+func StopControl() {
+	tmuxControlMu.Lock()
+	c := tmuxControl
+	tmuxControl = nil
+	tmuxControlMu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+func ActiveControl() *ControlClient {
+	tmuxControlMu.RLock()
+	defer tmuxControlMu.RUnlock()
+	if tmuxControl == nil || tmuxControl.isClosed() {
+		return nil
+	}
+	return tmuxControl
+}
+
+// Events fires (coalesced) whenever tmux reports a change worth a refresh.
+func (c *ControlClient) Events() <-chan struct{} {
+	return c.events
+}
+
+// Done is closed once the connection is gone.
+func (c *ControlClient) Done() <-chan struct{} {
+	return c.done
+}
+
+func dialTmuxControl(session string) (*ControlClient, error) {
+	// ignore-size keeps the control client out of window sizing and
+	// no-output stops %output traffic for panes we never read.
+	cmd := exec.Command("tmux", "-C", "attach-session", "-t", session, "-f", "no-output,ignore-size")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &ControlClient{
+		cmd:    cmd,
+		stdin:  stdin,
+		events: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+	return c, nil
+}
+
+func (c *ControlClient) readLoop(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	inBlock := false
+	blockID := ""
+	ours := false
+	var out []string
+	for sc.Scan() {
+		line := sc.Text()
+		if inBlock {
+			if kind, id, _, ok := parseControlGuard(line); ok && id == blockID && (kind == "%end" || kind == "%error") {
+				inBlock = false
+				if ours {
+					reply := controlReply{out: strings.Join(out, "\n")}
+					if kind == "%error" {
+						reply = controlReply{err: errors.New(strings.TrimSpace(reply.out))}
+					} else if reply.out != "" {
+						reply.out += "\n"
+					}
+					c.resolve(reply)
+				}
+				out = nil
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		if kind, id, flags, ok := parseControlGuard(line); ok && kind == "%begin" {
+			inBlock = true
+			blockID = id
+			// Flag 1 marks commands sent by this client; the implicit
+			// attach-session block at startup has flag 0.
+			ours = flags == "1"
+			out = nil
+			continue
+		}
+		name := strings.Fields(line)
+		if len(name) == 0 {
+			continue
+		}
+		if name[0] == "%exit" {
+			break
+		}
+		if controlNotifications[name[0]] {
+			select {
+			case c.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+	c.shutdown()
+}
+
+func (c *ControlClient) resolve(reply controlReply) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	ch := c.pending[0]
+	c.pending = c.pending[1:]
+	c.mu.Unlock()
+	ch <- reply
+}
+
+func (c *ControlClient) shutdown() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	pending := c.pending
+	c.pending = nil
+	close(c.done)
+	c.mu.Unlock()
+	for _, ch := range pending {
+		ch <- controlReply{err: errControlClosed}
+	}
+	_ = c.stdin.Close()
+	if c.cmd != nil {
+		_ = c.cmd.Wait()
+	}
+}
+
+func (c *ControlClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *ControlClient) Close() {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return
+	}
+	_ = c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		if c.cmd != nil && c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+	}
+}
+
+// Run sends one tmux command and waits for its output.
+func (c *ControlClient) Run(args ...string) (string, error) {
+	line, ok := controlCommandLine(args)
+	if !ok {
+		return "", errControlClosed
+	}
+	ch := make(chan controlReply, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return "", errControlClosed
+	}
+	c.pending = append(c.pending, ch)
+	_, err := io.WriteString(c.stdin, line+"\n")
+	c.mu.Unlock()
+	if err != nil {
+		return "", errControlClosed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), controlCommandTimeout)
+	defer cancel()
+	select {
+	case reply := <-ch:
+		return reply.out, reply.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("tmux %s timed out", args[0])
+	}
+}
+
+func parseControlGuard(line string) (string, string, string, bool) {
+	parts := strings.Fields(line)
+	if len(parts) != 4 {
+		return "", "", "", false
+	}
+	switch parts[0] {
+	case "%begin", "%end", "%error":
+		return parts[0], parts[1] + " " + parts[2], parts[3], true
+	}
+	return "", "", "", false
+}
+
+// controlCommandLine quotes args for the tmux command parser. Arguments with
+// newlines cannot be sent over the line protocol.
+func controlCommandLine(args []string) (string, bool) {
+	if len(args) == 0 {
+		return "", false
+	}
+	q := make([]string, 0, len(args))
+	for _, a := range args {
+		if strings.ContainsAny(a, "\n\r") {
+			return "", false
+		}
+		q = append(q, "'"+strings.ReplaceAll(a, "'", `'\''`)+"'")
+	}
+	return strings.Join(q, " "), true
+}
@@ -0,0 +1,175 @@
+// Package tmux wraps the tmux CLI, the control-mode client and the ssh
+// transport used for remote targets.
+package tmux
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func Run(args ...string) (string, error) {
+	if c := ActiveControl(); c != nil {
+		out, err := c.Run(args...)
+		if !errors.Is(err, errControlClosed) {
+			return out, err
+		}
+	}
+	return runOut("tmux", args...)
+}
+
+// CurrentSession returns the tmux session echoshell itself runs in, if any.
+func CurrentSession(b Backend) string {
+	if pane := strings.TrimSpace(os.Getenv("TMUX_PANE")); pane != "" {
+		if name, err := b.Display(pane, "#{session_name}"); err == nil && name != "" {
+			return name
+		}
+	}
+	if strings.TrimSpace(os.Getenv("TMUX")) != "" {
+		if name, err := b.Display("", "#{session_name}"); err == nil && name != "" {
+			return name
+		}
+	}
+	if tty, err := os.Readlink("/proc/self/fd/0"); err == nil {
+		tty = strings.TrimSpace(tty)
+		if tty != "" {
+			if name, derr := b.Display(tty, "#{session_name}"); derr == nil && name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func EnsureMouseMode(desired string) {
+	_, _ = Run("set-option", "-g", "mouse", desired)
+
+	for _, session := range listTargets("list-sessions", "-F", "#{session_name}") {
+		_, _ = Run("set-option", "-t", session, "mouse", desired)
+	}
+
+	for _, window := range listTargets("list-windows", "-a", "-F", "#{session_name}:#{window_index}") {
+		_, _ = Run("set-option", "-w", "-t", window, "mouse", desired)
+	}
+}
+
+func listTargets(args ...string) []string {
+	out, err := Run(args...)
+	if err != nil {
+		return nil
+	}
+	return parseTargets(out)
+}
+
+func parseTargets(out string) []string {
+	targets := []string{}
+	seen := map[string]bool{}
+	for _, ln := range strings.Split(out, "\n") {
+		v := strings.TrimSpace(ln)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		targets = append(targets, v)
+	}
+	return targets
+}
+
+func AttachCmd(session string) *exec.Cmd {
+	if strings.TrimSpace(os.Getenv("TMUX")) != "" {
+		return exec.Command("tmux", "switch-client", "-t", session)
+	}
+	return exec.Command("tmux", "attach-session", "-t", session)
+}
+
+func AttachNow(session string) error {
+	StopControl()
+	cmd := AttachCmd(session)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func sshControlPath() string {
+	return filepath.Join(os.TempDir(), "echoshell-ssh-%C")
+}
+
+func sshBaseArgs(target string) []string {
+	return []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=8",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPersist=120",
+		"-o", "ControlPath=" + sshControlPath(),
+		target,
+	}
+}
+
+func RunSSH(target, command string) (string, error) {
+	args := append(sshBaseArgs(target), "sh -lc "+ShellQuote(command))
+	return runOut("ssh", args...)
+}
+
+func ShellJoin(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	q := make([]string, 0, len(args))
+	for _, a := range args {
+		q = append(q, ShellQuote(a))
+	}
+	return strings.Join(q, " ")
+}
+
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"`$&|;<>*?[]{}()!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
+}
+
+func runOut(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out", name)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("%s", msg)
+	}
+	return stdout.String(), nil
+}
+
+func atoiSafe(s string) int {
+	n := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return n
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
@@ -0,0 +1,126 @@
+package tmux
+
+import (
+	"io"
+	"testing"
+)
+
+func TestAttachCmdUsesSwitchClientInsideTmux(t *testing.T) {
+	t.Setenv("TMUX", "1")
+	cmd := AttachCmd("my-session")
+
+	if len(cmd.Args) < 4 {
+		t.Fatalf("unexpected args: %#v", cmd.Args)
+	}
+	if cmd.Args[1] != "switch-client" {
+		t.Fatalf("expected switch-client, got %#v", cmd.Args)
+	}
+	if cmd.Args[3] != "my-session" {
+		t.Fatalf("expected target my-session, got %#v", cmd.Args)
+	}
+}
+
+func TestAttachCmdUsesAttachOutsideTmux(t *testing.T) {
+	t.Setenv("TMUX", "")
+	cmd := AttachCmd("my-session")
+
+	if len(cmd.Args) < 4 {
+		t.Fatalf("unexpected args: %#v", cmd.Args)
+	}
+	if cmd.Args[1] != "attach-session" {
+		t.Fatalf("expected attach-session, got %#v", cmd.Args)
+	}
+	if cmd.Args[3] != "my-session" {
+		t.Fatalf("expected target my-session, got %#v", cmd.Args)
+	}
+}
+
+func TestListTargetsParsesNonEmptyUniqueLines(t *testing.T) {
+	out := "s1\ns2\n\ns2\n  s3  \n"
+	targets := parseTargets(out)
+	if len(targets) != 3 {
+		t.Fatalf("expected 3 targets, got %d (%#v)", len(targets), targets)
+	}
+	if targets[0] != "s1" || targets[1] != "s2" || targets[2] != "s3" {
+		t.Fatalf("unexpected targets: %#v", targets)
+	}
+}
+
+type lineRecorder chan string
+
+func (r lineRecorder) Write(p []byte) (int, error) {
+	r <- string(p)
+	return len(p), nil
+}
+
+func (r lineRecorder) Close() error { return nil }
+
+func TestControlClientPairsRepliesAndSignalsEvents(t *testing.T) {
+	pr, pw := io.Pipe()
+	sent := make(lineRecorder, 2)
+	c := &ControlClient{
+		stdin:  sent,
+		events: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go c.readLoop(pr)
+
+	results := make(chan controlReply, 2)
+	go func() {
+		out, err := c.Run("list-sessions")
+		results <- controlReply{out: out, err: err}
+		out, err = c.Run("bogus")
+		results <- controlReply{out: out, err: err}
+	}()
+	if line := <-sent; line != "'list-sessions'\n" {
+		t.Fatalf("unexpected command line %q", line)
+	}
+	io.WriteString(pw, "%begin 1 10 0\n%end 1 10 0\n%sessions-changed\n%begin 1 11 1\napp-shell-1\n%end 1 11 1\n")
+	first := <-results
+	if first.err != nil || first.out != "app-shell-1\n" {
+		t.Fatalf("unexpected first reply: %#v", first)
+	}
+	<-sent
+	io.WriteString(pw, "%begin 1 12 1\nunknown command: bogus\n%error 1 12 1\n")
+	second := <-results
+	if second.err == nil || second.err.Error() != "unknown command: bogus" {
+		t.Fatalf("expected error reply, got %#v", second)
+	}
+	select {
+	case <-c.events:
+	default:
+		t.Fatalf("expected sessions-changed to signal an event")
+	}
+
+	pw.Close()
+	<-c.done
+	if _, err := c.Run("list-sessions"); err != errControlClosed {
+		t.Fatalf("expected closed client error, got %v", err)
+	}
+}
+
+func TestControlCommandLineQuotesArgs(t *testing.T) {
+	line, ok := controlCommandLine([]string{"display-message", "-p", "it's #{session_name}"})
+	if !ok {
+		t.Fatalf("expected command line")
+	}
+	if line != `'display-message' '-p' 'it'\''s #{session_name}'` {
+		t.Fatalf("unexpected command line: %s", line)
+	}
+	if _, ok := controlCommandLine([]string{"send-keys", "a\nb"}); ok {
+		t.Fatalf("expected newline arg to be rejected")
+	}
+}
+
+func TestSplitEscapedUnquotesFields(t *testing.T) {
+	got := splitEscaped(`app-1|1|/git/a\|b|IS_SANDBOX\=1\ claude\ \'x\'|`, '|')
+	want := []string{"app-1", "1", "/git/a|b", "IS_SANDBOX=1 claude 'x'", ""}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected fields: %#v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("field %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
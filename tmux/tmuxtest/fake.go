@@ -0,0 +1,267 @@
+// Package tmuxtest provides an in-memory tmux backend for tests.
+package tmuxtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"echoshell/tmux"
+)
+
+// Session seeds a Fake with one session.
+type Session struct {
+	Name     string
+	Path     string
+	Command  string
+	Attached bool
+	Windows  int
+	// Dir is the start directory; it defaults to Path.
+	Dir string
+	// Panes are the current paths of extra panes after the first.
+	Panes []string
+	// More holds the pane paths of each window after the first.
+	More    [][]string
+	Layouts map[int]string
+	Options map[string]string
+	// Env is the environment the session was created with.
+	Env map[string]string
+}
+
+// Fake is an in-memory tmux.Backend. Every session has a first pane whose
+// id is derived from its position at creation, plus one pane per Panes entry.
+type Fake struct {
+	sessions []Session
+	paneIDs  map[string]string
+	Current  string
+	Keys     map[string][]string
+	Captures map[string]string
+	// Respawned lists pane ids in the order RespawnPane was called.
+	Respawned []string
+	// Started holds the shell-command passed to NewSession or RespawnPane,
+	// keyed by session name or pane id.
+	Started  map[string]string
+	nextPane int
+}
+
+func NewFake(sessions ...Session) *Fake {
+	f := &Fake{paneIDs: map[string]string{}, Keys: map[string][]string{}, Captures: map[string]string{}, Started: map[string]string{}}
+	for _, s := range sessions {
+		f.Add(s)
+	}
+	return f
+}
+
+func (f *Fake) Add(s Session) {
+	if s.Command == "" {
+		s.Command = "bash"
+	}
+	if s.Windows == 0 {
+		s.Windows = 1
+	}
+	if s.Dir == "" {
+		s.Dir = s.Path
+	}
+	if s.Options == nil {
+		s.Options = map[string]string{}
+	}
+	if s.Layouts == nil {
+		s.Layouts = map[int]string{}
+	}
+	s.Windows = max(s.Windows, 1+len(s.More))
+	f.nextPane++
+	f.paneIDs[s.Name] = fmt.Sprintf("%%%d", f.nextPane)
+	f.sessions = append(f.sessions, s)
+}
+
+func (f *Fake) find(name string) int {
+	name = strings.SplitN(name, ":", 2)[0]
+	for i, s := range f.sessions {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *Fake) Names() []string {
+	out := []string{}
+	for _, s := range f.sessions {
+		out = append(out, s.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Session returns the named session as currently stored.
+func (f *Fake) Session(name string) (Session, bool) {
+	i := f.find(name)
+	if i < 0 {
+		return Session{}, false
+	}
+	return f.sessions[i], true
+}
+
+func (f *Fake) ListSessions() ([]tmux.Session, error) {
+	out := []tmux.Session{}
+	for _, s := range f.sessions {
+		out = append(out, tmux.Session{
+			Name:     s.Name,
+			Attached: s.Attached,
+			Windows:  s.Windows,
+			Path:     s.Dir,
+			Options:  s.Options,
+		})
+	}
+	return out, nil
+}
+
+func (f *Fake) ListPanes() ([]tmux.Pane, error) {
+	out := []tmux.Pane{}
+	for _, s := range f.sessions {
+		out = append(out, tmux.Pane{ID: f.paneIDs[s.Name], Session: s.Name, Command: s.Command, Path: s.Path})
+		for i, p := range s.Panes {
+			out = append(out, tmux.Pane{ID: fmt.Sprintf("%s.%d", f.paneIDs[s.Name], i+1), Session: s.Name, Index: i + 1, Command: "bash", Path: p})
+		}
+		for w, paths := range s.More {
+			for i, p := range paths {
+				out = append(out, tmux.Pane{ID: fmt.Sprintf("%s.w%d.%d", f.paneIDs[s.Name], w+1, i), Session: s.Name, Window: w + 1, Index: i, Command: "bash", Path: p})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) ListWindows() ([]tmux.Window, error) {
+	out := []tmux.Window{}
+	for _, s := range f.sessions {
+		for w := 0; w < s.Windows; w++ {
+			out = append(out, tmux.Window{Session: s.Name, Index: w, Layout: s.Layouts[w]})
+		}
+	}
+	return out, nil
+}
+
+// window resolves a "session" or "session:index" target.
+func (f *Fake) window(target string) (int, int) {
+	i := f.find(target)
+	w := 0
+	if _, idx, ok := strings.Cut(target, ":"); ok {
+		fmt.Sscanf(idx, "%d", &w)
+	}
+	return i, w
+}
+
+func (f *Fake) NewWindow(session, name, dir string) (string, error) {
+	i := f.find(session)
+	if i < 0 {
+		return "", errors.New("can't find session: " + session)
+	}
+	f.sessions[i].More = append(f.sessions[i].More, []string{dir})
+	f.sessions[i].Windows++
+	return fmt.Sprintf("%s:%d", f.sessions[i].Name, len(f.sessions[i].More)), nil
+}
+
+func (f *Fake) SplitPane(target, dir string) error {
+	i, w := f.window(target)
+	if i < 0 {
+		return errors.New("can't find window: " + target)
+	}
+	if w == 0 {
+		f.sessions[i].Panes = append(f.sessions[i].Panes, dir)
+		return nil
+	}
+	f.sessions[i].More[w-1] = append(f.sessions[i].More[w-1], dir)
+	return nil
+}
+
+func (f *Fake) SelectLayout(target, layout string) error {
+	i, w := f.window(target)
+	if i < 0 {
+		return errors.New("can't find window: " + target)
+	}
+	f.sessions[i].Layouts[w] = layout
+	return nil
+}
+
+func (f *Fake) NewSession(name, dir string, env map[string]string, command string) error {
+	if f.find(name) >= 0 {
+		return errors.New("duplicate session: " + name)
+	}
+	f.Add(Session{Name: name, Path: dir, Env: env})
+	if command != "" {
+		f.Started[name] = command
+	}
+	return nil
+}
+
+func (f *Fake) SendKeys(target string, keys ...string) error {
+	if f.find(target) < 0 {
+		return errors.New("can't find pane: " + target)
+	}
+	f.Keys[target] = append(f.Keys[target], keys...)
+	return nil
+}
+
+func (f *Fake) KillSession(name string) error {
+	i := f.find(name)
+	if i < 0 {
+		return errors.New("can't find session: " + name)
+	}
+	f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+	return nil
+}
+
+func (f *Fake) RenameSession(name, newName string) error {
+	i := f.find(name)
+	if i < 0 {
+		return errors.New("can't find session: " + name)
+	}
+	if f.find(newName) >= 0 {
+		return errors.New("duplicate session: " + newName)
+	}
+	f.paneIDs[newName] = f.paneIDs[name]
+	f.sessions[i].Name = newName
+	return nil
+}
+
+func (f *Fake) SetOption(target, option, value string) error {
+	i := f.find(target)
+	if i < 0 {
+		return errors.New("can't find session: " + target)
+	}
+	f.sessions[i].Options[option] = value
+	return nil
+}
+
+func (f *Fake) CapturePane(target string) (string, error) {
+	if f.find(target) < 0 {
+		return "", errors.New("can't find pane: " + target)
+	}
+	return f.Captures[strings.SplitN(target, ":", 2)[0]], nil
+}
+
+func (f *Fake) SplitWindow(target string, percent int, command string) (string, error) {
+	f.nextPane++
+	return fmt.Sprintf("%%%d", f.nextPane), nil
+}
+
+func (f *Fake) RespawnPane(pane, dir, command string) error {
+	f.Respawned = append(f.Respawned, pane)
+	if command != "" {
+		f.Started[pane] = command
+	}
+	return nil
+}
+
+func (f *Fake) KillPane(pane string) error { return nil }
+
+func (f *Fake) SelectPane(pane string) error { return nil }
+
+func (f *Fake) Display(target, format string) (string, error) {
+	if format == "#{session_name}" && f.Current != "" {
+		return f.Current, nil
+	}
+	return "", errors.New("no current client")
+}
@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"echoshell/config"
+)
+
+// keymap is the active keymap; models built without one use the defaults.
+func (m model) keymap() config.Keymap {
+	if m.keys == nil {
+		return config.DefaultKeymap()
+	}
+	return m.keys
+}
+
+// updateHelp closes the key overlay on any key.
+func (m model) updateHelp(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			cleanupSoftPreview(&m)
+			return m, tea.Quit
+		}
+		m.showingHelp = false
+	}
+	return m, nil
+}
+
+// helpLine is the main view's one-line help: the first key of each action
+// with a short label.
+func (m model) helpLine() string {
+	km := m.keymap()
+	parts := []string{"1-9 repo"}
+	for _, a := range config.KeyActions() {
+		if a.Short == "" || len(km[a.Name]) == 0 {
+			continue
+		}
+		parts = append(parts, km[a.Name][0]+" "+a.Short)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// renderHelp lists every action with all its keys.
+func (m model) renderHelp() string {
+	km := m.keymap()
+	rows := [][2]string{{"1-9", "select repo"}}
+	for _, a := range config.KeyActions() {
+		keys := "(unbound)"
+		if len(km[a.Name]) > 0 {
+			keys = strings.Join(km[a.Name], " / ")
+		}
+		rows = append(rows, [2]string{keys, a.Help})
+	}
+	rows = append(rows, [2]string{"ctrl+c", "quit"})
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r[0]))
+	}
+
+	keyStyle := lipgloss.NewStyle().Foreground(color(theme.Selected))
+	lines := []string{lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Keys"), ""}
+	for _, r := range rows {
+		lines = append(lines, keyStyle.Render(fmt.Sprintf("%-*s", width, r[0]))+"  "+r[1])
+	}
+	return lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,215 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+
+	"echoshell/discovery"
+	"echoshell/state"
+)
+
+// listRow is one line of the repo list: a repo row (session -1), one of its
+// sessions, or the blank separator between repos (repo -1).
+type listRow struct {
+	repo    int
+	session int
+}
+
+// listRows lays out the repos in repoOrder with the sessions of expanded
+// repos under them.
+func (m model) listRows() []listRow {
+	order := m.repoOrder()
+	rows := make([]listRow, 0, len(order)*2)
+	for n, i := range order {
+		if n > 0 {
+			rows = append(rows, listRow{repo: -1, session: -1})
+		}
+		rows = append(rows, listRow{repo: i, session: -1})
+		for si := range m.visibleSessions(i) {
+			rows = append(rows, listRow{repo: i, session: si})
+		}
+	}
+	return rows
+}
+
+// selectedRow is the index of the selection in rows, or -1.
+func (m model) selectedRow(rows []listRow) int {
+	for i, r := range rows {
+		if r.repo == m.selectedWorkspace && r.session == m.selectedSession {
+			return i
+		}
+	}
+	return -1
+}
+
+// listLines is how many rows fit in a repo box of the given height, after
+// its border, padding and title.
+func listLines(height int) int {
+	return max(1, height-6)
+}
+
+// bodyHeight is the height of the repo box, 0 when the terminal size is not
+// known yet. Layout is: title (1) + body + status (1) + help (1).
+func (m model) bodyHeight() int {
+	if m.height <= 0 {
+		return 0
+	}
+	return max(8, m.height-3)
+}
+
+// scrollOffset moves offset as little as possible so row sel is within the
+// window of size lines over total rows.
+func scrollOffset(offset, sel, total, lines int) int {
+	if sel >= 0 {
+		if sel < offset {
+			offset = sel
+		}
+		if sel >= offset+lines {
+			offset = sel - lines + 1
+		}
+	}
+	return max(0, min(offset, total-lines))
+}
+
+// scrollToSelection keeps the selection inside the visible part of the list.
+func (m *model) scrollToSelection() {
+	h := m.bodyHeight()
+	if h == 0 {
+		return
+	}
+	rows := m.listRows()
+	m.listOffset = scrollOffset(m.listOffset, m.selectedRow(rows), len(rows), listLines(h))
+}
+
+// pageSelection moves the selection by delta selectable rows without
+// wrapping; a delta past either end stops at the first or last row.
+func (m *model) pageSelection(delta int) bool {
+	rows := m.listRows()
+	selectable := make([]listRow, 0, len(rows))
+	for _, r := range rows {
+		if r.repo >= 0 {
+			selectable = append(selectable, r)
+		}
+	}
+	if len(selectable) == 0 {
+		return false
+	}
+	cur := 0
+	for i, r := range selectable {
+		if r.repo == m.selectedWorkspace && r.session == m.selectedSession {
+			cur = i
+			break
+		}
+	}
+	next := max(0, min(len(selectable)-1, cur+delta))
+	if next == cur {
+		return false
+	}
+	m.selectedWorkspace = selectable[next].repo
+	m.selectedSession = selectable[next].session
+	m.captureActive()
+	return true
+}
+
+// pageSize is how many rows PgUp/PgDn move.
+func (m model) pageSize() int {
+	if h := m.bodyHeight(); h > 0 {
+		return max(1, listLines(h)-1)
+	}
+	return 10
+}
+
+// positionIndicator tells which rows of total are shown, empty when all fit.
+func positionIndicator(start, end, total int) string {
+	if start == 0 && end >= total {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d of %d", start+1, end, total)
+}
+
+// visibleSessions are the sessions listed under group i: none while it is
+// collapsed.
+func (m model) visibleSessions(i int) []discovery.SessionInfo {
+	if i < 0 || i >= len(m.groups) || m.isCollapsed(m.groups[i].Name) {
+		return nil
+	}
+	return m.groups[i].Sessions
+}
+
+func (m model) isCollapsed(name string) bool {
+	return slices.Contains(m.view.Collapsed, name)
+}
+
+func (m model) isPinned(name string) bool {
+	return slices.Contains(m.view.Pinned, name)
+}
+
+// toggleCollapsed folds or unfolds the selected repo's sessions.
+func (m *model) toggleCollapsed() {
+	if m.selectedWorkspace < 0 || m.selectedWorkspace >= len(m.groups) {
+		return
+	}
+	name := m.groups[m.selectedWorkspace].Name
+	m.view.Collapsed = toggleName(m.view.Collapsed, name)
+	if m.isCollapsed(name) {
+		m.selectedSession = -1
+		m.status = "Collapsed " + m.groups[m.selectedWorkspace].Repo
+	} else {
+		m.status = "Expanded " + m.groups[m.selectedWorkspace].Repo
+	}
+	m.captureActive()
+	m.saveView()
+}
+
+// togglePinned moves the selected repo to or from the top of the list.
+func (m *model) togglePinned() {
+	if m.selectedWorkspace < 0 || m.selectedWorkspace >= len(m.groups) {
+		return
+	}
+	name := m.groups[m.selectedWorkspace].Name
+	m.view.Pinned = toggleName(m.view.Pinned, name)
+	if m.isPinned(name) {
+		m.status = "Pinned " + m.groups[m.selectedWorkspace].Repo
+	} else {
+		m.status = "Unpinned " + m.groups[m.selectedWorkspace].Repo
+	}
+	m.saveView()
+}
+
+// toggleHideEmpty shows or hides repos without sessions. Pinned repos always
+// show.
+func (m *model) toggleHideEmpty() {
+	m.view.HideEmpty = !m.view.HideEmpty
+	if m.view.HideEmpty {
+		m.status = "Hiding repos without sessions"
+	} else {
+		m.status = "Showing all repos"
+	}
+	m.selectVisibleRepo()
+	m.saveView()
+}
+
+// selectVisibleRepo moves the selection to the first listed repo when the
+// selected one is not listed.
+func (m *model) selectVisibleRepo() {
+	order := m.repoOrder()
+	if len(order) == 0 || slices.Contains(order, m.selectedWorkspace) {
+		return
+	}
+	m.selectedWorkspace = order[0]
+	m.selectedSession = defaultSessionIndex(m.visibleSessions(order[0]))
+	m.captureActive()
+}
+
+func (m *model) saveView() {
+	if err := state.SaveView(m.view); err != nil {
+		m.status = "Saving view failed: " + err.Error()
+	}
+}
+
+func toggleName(names []string, name string) []string {
+	if i := slices.Index(names, name); i >= 0 {
+		return slices.Delete(slices.Clone(names), i, i+1)
+	}
+	return append(slices.Clone(names), name)
+}
@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// doubleClick is how close two clicks on the same row must be to attach.
+const doubleClick = 400 * time.Millisecond
+
+// listTop is the screen line of the first list row: the echoshell title, the
+// repo box border and padding, and the box's title and blank line.
+const listTop = 5
+
+// handleMouse selects the clicked row, attaches on a double-click, opens the
+// menu on a right-click and moves the selection with the wheel.
+func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp, tea.MouseButtonWheelDown:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+		delta := 1
+		if msg.Button == tea.MouseButtonWheelUp {
+			delta = -1
+		}
+		if m.pageSelection(delta) {
+			return m, previewCmdForSelection(m)
+		}
+		return m, nil
+	case tea.MouseButtonLeft, tea.MouseButtonRight:
+	default:
+		return m, nil
+	}
+	if msg.Action != tea.MouseActionPress {
+		return m, nil
+	}
+	row, ok := m.rowAt(msg.Y)
+	if !ok {
+		return m, nil
+	}
+	rows := m.listRows()
+	changed := m.selectedRow(rows) != row
+	m.selectedWorkspace = rows[row].repo
+	m.selectedSession = rows[row].session
+	m.captureActive()
+
+	if msg.Button == tea.MouseButtonRight {
+		m.lastClick = time.Time{}
+		m.menuItems = buildMenuItems(m)
+		m.selectedMenu = 0
+		m.selectingMenu = true
+		m.status = "Menu"
+		return m, previewCmdForSelection(m)
+	}
+
+	now := time.Now()
+	if !changed && row == m.lastClickRow && now.Sub(m.lastClick) <= doubleClick {
+		m.lastClick = time.Time{}
+		return m.enterSelection()
+	}
+	m.lastClick, m.lastClickRow = now, row
+	if changed {
+		return m, previewCmdForSelection(m)
+	}
+	return m, nil
+}
+
+// rowAt is the index in listRows of the selectable row drawn on screen line
+// y, if any.
+func (m model) rowAt(y int) (int, bool) {
+	if len(m.groups) == 0 || y < listTop {
+		return 0, false
+	}
+	rows := m.listRows()
+	line := y - listTop
+	if h := m.bodyHeight(); h > 0 && line >= listLines(h) {
+		return 0, false
+	}
+	row := m.listOffset + line
+	if row >= len(rows) || rows[row].repo < 0 {
+		return 0, false
+	}
+	return row, true
+}
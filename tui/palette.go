@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"echoshell/config"
+	"echoshell/match"
+)
+
+// paletteItem is one command palette entry. Action is a runAction name or
+// "new:<template>".
+type paletteItem struct {
+	Label  string
+	Action string
+	Keys   string
+}
+
+// paletteItems lists every keymap action, a new-session entry per template
+// and the actions that have no key. There are no remote switching entries:
+// remoteTarget is always local, so they could not switch anything.
+func (m model) paletteItems() []paletteItem {
+	km := m.keymap()
+	items := []paletteItem{}
+	for _, a := range config.KeyActions() {
+		if a.Name == "palette" {
+			continue
+		}
+		items = append(items, paletteItem{Label: strings.ToUpper(a.Help[:1]) + a.Help[1:], Action: a.Name, Keys: strings.Join(km[a.Name], " / ")})
+	}
+	for _, t := range m.repoTemplates() {
+		items = append(items, paletteItem{Label: "New session: " + t.Label, Action: "new:" + t.Name})
+	}
+	return append(items,
+		paletteItem{Label: "Export sessions to a snapshot", Action: "export"},
+		paletteItem{Label: "Update from origin/main", Action: "update"},
+	)
+}
+
+// paletteMatches are the items matching the query, best first; all of them
+// in order for an empty query.
+func (m model) paletteMatches() []paletteItem {
+	items := m.paletteItems()
+	if strings.TrimSpace(m.paletteQuery) == "" {
+		return items
+	}
+	type scored struct {
+		item  paletteItem
+		score int
+	}
+	found := []scored{}
+	for _, it := range items {
+		if score, ok := match.ScoreHay(m.paletteQuery, it.Label+" "+it.Action, true); ok {
+			found = append(found, scored{it, score})
+		}
+	}
+	slices.SortStableFunc(found, func(a, b scored) int { return b.score - a.score })
+	out := make([]paletteItem, len(found))
+	for i, f := range found {
+		out[i] = f.item
+	}
+	return out
+}
+
+func (m model) updatePalette(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			cleanupSoftPreview(&m)
+			return m, tea.Quit
+		case "esc":
+			m.selectingPalette = false
+			return m, nil
+		case "up":
+			if m.selectedPalette > 0 {
+				m.selectedPalette--
+			}
+		case "down":
+			if m.selectedPalette < len(m.paletteMatches())-1 {
+				m.selectedPalette++
+			}
+		case "enter":
+			items := m.paletteMatches()
+			if len(items) == 0 {
+				return m, nil
+			}
+			m.selectingPalette = false
+			return m.runPaletteItem(items[m.selectedPalette])
+		case "backspace":
+			if len(m.paletteQuery) > 0 {
+				m.paletteQuery = m.paletteQuery[:len(m.paletteQuery)-1]
+				m.selectedPalette = 0
+			}
+		default:
+			if len(msg.String()) == 1 {
+				m.paletteQuery += msg.String()
+				m.selectedPalette = 0
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m model) runPaletteItem(it paletteItem) (tea.Model, tea.Cmd) {
+	if name, ok := strings.CutPrefix(it.Action, "new:"); ok {
+		tpl := templateByName(m.repoTemplates(), name)
+		m.status = "Creating " + tpl.Label + " session..."
+		return m, newSessionCmd(m.newSessionPath(), m.newSessionRepo(), tpl)
+	}
+	return m.runAction(it.Action)
+}
+
+// renderPalette shows the query and a window of matches around the
+// selection, each with its keys.
+func (m model) renderPalette() string {
+	heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Commands")
+	cursor := lipgloss.NewStyle().Foreground(color(theme.Selected)).Render("▊")
+	input := lipgloss.NewStyle().Foreground(color(theme.Status)).Render("> " + m.paletteQuery)
+	keyStyle := lipgloss.NewStyle().Foreground(color(theme.Help))
+	norm := lipgloss.NewStyle().Padding(0, 1)
+
+	items := m.paletteMatches()
+	lines := []string{heading, "", input + cursor, ""}
+	if len(items) == 0 {
+		lines = append(lines, norm.Render("(no matching command)"))
+	}
+	n := 12
+	if m.height > 0 {
+		n = max(3, m.height-12)
+	}
+	start := scrollOffset(0, m.selectedPalette, len(items), n)
+	labelW := 0
+	for _, it := range items {
+		labelW = max(labelW, len(it.Label))
+	}
+	for i := start; i < min(len(items), start+n); i++ {
+		label := fmt.Sprintf("%-*s", labelW, items[i].Label)
+		if i == m.selectedPalette {
+			label = selectedStyle().Padding(0, 1).Render(label)
+		} else {
+			label = norm.Render(label)
+		}
+		lines = append(lines, label+"  "+keyStyle.Render(items[i].Keys))
+	}
+	return lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+}
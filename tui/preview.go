@@ -0,0 +1,135 @@
+package tui
+
+import (
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"echoshell/session"
+	"echoshell/tmux"
+)
+
+func previewCmdForSelection(m model) tea.Cmd {
+	sel, ok := m.selectedSessionInfo()
+	if !ok {
+		if len(m.groups) == 0 || m.selectedWorkspace < 0 || m.selectedWorkspace >= len(m.groups) {
+			return nil
+		}
+		sessions := m.groups[m.selectedWorkspace].Sessions
+		if len(sessions) == 0 {
+			return nil
+		}
+		sel = sessions[0]
+	}
+	if m.previewPane != "" && m.previewSession == sel.Name {
+		return nil
+	}
+	if splitTarget, ok := detectSoftAttachTarget(); ok {
+		return softAttachPreviewCmd(m.previewPane, splitTarget, sel.Name)
+	}
+	return softAttachPreviewCmd(m.previewPane, "", sel.Name)
+}
+
+func (m model) softAttachPreviewEnabled() bool {
+	return strings.TrimSpace(m.previewPane) != ""
+}
+
+func softAttachPreviewCmd(currentPane, splitTarget, session string) tea.Cmd {
+	return func() tea.Msg {
+		pane, err := ensureSoftPreviewPane(currentPane, splitTarget, session)
+		return softAttachMsg{pane: pane, session: session, err: err}
+	}
+}
+
+func ensureSoftPreviewPane(currentPane, splitTarget, session string) (string, error) {
+	cmd := softAttachPaneCommand(session)
+	owner := strings.TrimSpace(splitTarget)
+	pane := strings.TrimSpace(currentPane)
+	if pane != "" {
+		if _, err := tmuxClient.Display(pane, "#{pane_id}"); err == nil {
+			if err := tmuxClient.RespawnPane(pane, "", cmd); err != nil {
+				return "", err
+			}
+			if owner != "" {
+				_ = tmuxClient.SelectPane(owner)
+			}
+			return pane, nil
+		}
+	}
+
+	pane, err := tmuxClient.SplitWindow(owner, 75, cmd)
+	if err != nil {
+		return "", err
+	}
+	if owner != "" {
+		_ = tmuxClient.SelectPane(owner)
+	}
+	return pane, nil
+}
+
+func softAttachPaneCommand(session string) string {
+	return "TMUX= tmux attach-session -r -t " + tmux.ShellQuote(session)
+}
+
+func detectSoftAttachTarget() (string, bool) {
+	if strings.TrimSpace(os.Getenv("TMUX")) != "" {
+		if pane, err := tmuxClient.Display("", "#{pane_id}"); err == nil && pane != "" {
+			return pane, true
+		}
+		return "", true
+	}
+
+	tty, err := os.Readlink("/proc/self/fd/0")
+	if err == nil {
+		tty = strings.TrimSpace(tty)
+		if tty != "" {
+			if pane, derr := tmuxClient.Display(tty, "#{pane_id}"); derr == nil && pane != "" {
+				return pane, true
+			}
+		}
+	}
+
+	panes, err := tmuxClient.ListPanes()
+	if err != nil {
+		return "", false
+	}
+	for _, p := range panes {
+		if p.ID != "" {
+			return p.ID, true
+		}
+	}
+	return "", false
+}
+
+func cleanupSoftPreview(m *model) {
+	cleanupSoftPreviewPane(m.previewPane)
+	m.previewPane = ""
+	m.previewSession = ""
+}
+
+func cleanupSoftPreviewPane(pane string) {
+	pane = strings.TrimSpace(pane)
+	if pane == "" {
+		return
+	}
+	_ = tmuxClient.KillPane(pane)
+}
+
+func (m model) canFocusSoftAttach() bool {
+	return strings.TrimSpace(m.previewPane) != "" && strings.TrimSpace(os.Getenv("TMUX")) != ""
+}
+
+func (m model) focusSoftAttach() {
+	if !m.canFocusSoftAttach() {
+		return
+	}
+	_ = tmuxClient.SelectPane(m.previewPane)
+}
+
+func loadPreviewCmd(name string) tea.Cmd {
+	return func() tea.Msg {
+		text, err := session.Capture(tmuxClient, name)
+		return previewMsg{session: name, text: text, err: err}
+	}
+}
@@ -0,0 +1,29 @@
+package tui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+
+	"echoshell/config"
+)
+
+// theme is the active color theme, set by Run.
+var theme = config.Themes()[config.ThemeDark]
+
+// color turns a theme color into a lipgloss color; "" is the terminal's
+// default.
+func color(c string) lipgloss.TerminalColor {
+	if c == "" {
+		return lipgloss.NoColor{}
+	}
+	return lipgloss.Color(c)
+}
+
+// selectedStyle highlights the selected row. Themes without a selection
+// background, like no-color, use reverse video.
+func selectedStyle() lipgloss.Style {
+	s := lipgloss.NewStyle().Foreground(color(theme.Selected)).Background(color(theme.SelectedBg))
+	if theme.SelectedBg == "" {
+		s = s.Reverse(true)
+	}
+	return s
+}
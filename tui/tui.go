@@ -0,0 +1,1744 @@
+// Package tui is the interactive session picker.
+package tui
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/match"
+	"echoshell/session"
+	"echoshell/state"
+	"echoshell/tmux"
+)
+
+const refreshInterval = 2 * time.Second
+
+const controlRefreshInterval = 15 * time.Second
+
+const maxPreviewLines = 8
+
+var selectedRemoteTarget = ""
+
+var updateRepoDir = ""
+
+var tmuxClient tmux.Backend = tmux.Local()
+
+type loadedMsg struct {
+	groups []discovery.WorkspaceGroup
+	err    error
+}
+
+type tickMsg time.Time
+
+type tmuxEventMsg struct {
+	client *tmux.ControlClient
+}
+
+type actionMsg struct {
+	status string
+	err    error
+}
+
+type attachResultMsg struct {
+	err error
+	// hadControl is set when attachCmd stopped a running control client,
+	// which a failed attach has to bring back.
+	hadControl bool
+}
+
+type createdMsg struct {
+	name   string
+	status string
+	err    error
+}
+
+type viewCreatedMsg struct {
+	name  string
+	count int
+	err   error
+}
+
+type previewMsg struct {
+	session string
+	text    string
+	err     error
+}
+
+type softAttachMsg struct {
+	pane    string
+	session string
+	err     error
+}
+
+type remoteMsg struct {
+	target string
+	err    error
+}
+
+type menuItem struct {
+	Label string
+	Key   string
+}
+
+type model struct {
+	width              int
+	height             int
+	groups             []discovery.WorkspaceGroup
+	selectedWorkspace  int
+	selectedSession    int // -1 means repo row selected
+	multiSelected      map[string]bool
+	previewErr         bool
+	selectingMenu      bool
+	menuItems          []menuItem
+	selectedMenu       int
+	preferredWorkspace string
+	activeWorkspace    string
+	activeSession      string
+	previewSession     string
+	previewText        string
+	previewPane        string
+	updateBusy         bool
+	status             string
+	selectingRemote    bool
+	availableTargets   []string
+	selectedTarget     int
+	addingNewRemote    bool
+	newRemoteInput     string
+	selectingNew       bool
+	newTemplates       []config.Template
+	selectedTemplate   int
+	selectingQuick     bool
+	quickQuery         string
+	quickCandidates    []match.Candidate
+	selectedQuick      int
+	confirmingCreate   bool
+	quickCreate        match.CreatePlan
+	lastClick          time.Time
+	lastClickRow       int
+	renamingSession    bool
+	renameTarget       string
+	renamePrefix       string
+	renameInput        string
+	view               state.View
+	listOffset         int // first list row shown
+	keys               config.Keymap
+	showingHelp        bool
+	selectingPalette   bool
+	paletteQuery       string
+	selectedPalette    int
+}
+
+// Options are the command-line inputs the picker starts from.
+type Options struct {
+	// Tokens is the quick-attach query, if any.
+	Tokens []string
+	// Create skips the confirmation when Tokens match no session.
+	Create bool
+}
+
+// Run starts the picker. The caller must already be inside tmux.
+func Run(opts Options) error {
+	if mode, manage := config.TmuxMouseMode(); manage {
+		tmux.EnsureMouseMode(mode)
+	}
+
+	selectedRemoteTarget = config.DefaultTarget
+	tmuxClient = tmux.ForTarget(remoteTarget())
+
+	if config.TmuxControlEnabled() && isLocalRemote() {
+		if err := tmux.StartControl(tmux.CurrentSession(tmuxClient)); err == nil {
+			defer tmux.StopControl()
+		}
+	}
+
+	status := "Loading sessions..."
+	if config.AutoRestore() && isLocalRemote() {
+		if msg := autoRestore(); msg != "" {
+			status = msg
+		}
+	}
+
+	updateRepoDir = detectRepoDir()
+	preferredWorkspace, _ := state.LastWorkspace(selectedRemoteTarget)
+	view, _ := state.LoadView()
+	keys, err := config.LoadKeymap()
+	if err != nil {
+		return err
+	}
+	if theme, err = config.LoadTheme(); err != nil {
+		return err
+	}
+
+	m := model{
+		status:             status,
+		selectingRemote:    false,
+		availableTargets:   []string{"local"},
+		selectedTarget:     0,
+		preferredWorkspace: preferredWorkspace,
+		newTemplates:       config.DefaultTemplates(),
+		multiSelected:      map[string]bool{},
+		view:               view,
+		keys:               keys,
+	}
+
+	tokens := opts.Tokens
+	if len(tokens) > 0 {
+		matches, qerr := findQuickCandidates(tokens)
+		if qerr == nil && len(matches) == 1 {
+			return tmux.AttachNow(matches[0].Session.Name)
+		}
+		if qerr == nil && len(matches) > 1 {
+			m.selectingQuick = true
+			m.quickQuery = strings.Join(tokens, " ")
+			m.quickCandidates = matches
+			m.selectedQuick = 0
+			m.status = fmt.Sprintf("%d matches for %q", len(matches), m.quickQuery)
+		}
+		if qerr == nil && len(matches) == 0 {
+			plan, ok, perr := findQuickCreate(tokens, m.newTemplates)
+			if perr != nil {
+				return perr
+			}
+			if opts.Create {
+				if !ok {
+					return fmt.Errorf("no repo or template matches %q", strings.Join(tokens, " "))
+				}
+				name, err := session.Create(tmuxClient, plan.Path, plan.Repo, plan.Template)
+				if err != nil {
+					return err
+				}
+				return tmux.AttachNow(name)
+			}
+			if ok {
+				m.confirmingCreate = true
+				m.quickQuery = strings.Join(tokens, " ")
+				m.quickCreate = plan
+				m.status = fmt.Sprintf("No session matches %q", m.quickQuery)
+			}
+		}
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	_, err = p.Run()
+	return err
+}
+
+// autoRestore brings back the saved sessions when none of them is running,
+// which is what a freshly booted tmux server looks like.
+func autoRestore() string {
+	snap, err := state.LoadSnapshot()
+	if err != nil {
+		return "Restore failed: " + err.Error()
+	}
+	if len(snap.Sessions) == 0 {
+		return ""
+	}
+	running, err := tmuxClient.ListSessions()
+	if err != nil {
+		return "Restore failed: " + err.Error()
+	}
+	for _, r := range running {
+		for _, saved := range snap.Sessions {
+			if r.Name == saved.Name {
+				return ""
+			}
+		}
+	}
+	restored, _, err := session.Restore(tmuxClient, snap, config.DefaultTemplates())
+	if err != nil {
+		return "Restore failed: " + err.Error()
+	}
+	return fmt.Sprintf("Restored %d sessions", len(restored))
+}
+
+func loadTargetsForSelection(lastTarget string) ([]string, int) {
+	targets, err := state.Targets()
+	if err != nil || len(targets) == 0 {
+		targets = []string{"local"}
+	}
+	targets = append(targets, "+ Add new remote...")
+	selectedIdx := 0
+	for i, t := range targets {
+		if t == lastTarget {
+			selectedIdx = i
+			break
+		}
+	}
+	return targets, selectedIdx
+}
+
+func findQuickCandidates(tokens []string) ([]match.Candidate, error) {
+	groups, err := groupedSessions()
+	if err != nil {
+		return nil, err
+	}
+	return match.Candidates(groups, tokens), nil
+}
+
+func findQuickCreate(tokens []string, templates []config.Template) (match.CreatePlan, bool, error) {
+	groups, err := discovery.RepoGroupsCached(remoteTarget())
+	if err != nil {
+		return match.CreatePlan{}, false, err
+	}
+	plan, ok := match.PlanCreate(tokens, groups, templates)
+	return plan, ok, nil
+}
+
+func groupedSessions() ([]discovery.WorkspaceGroup, error) {
+	return discovery.GroupSessions(tmuxClient, remoteTarget())
+}
+
+func (m model) Init() tea.Cmd {
+	if m.selectingRemote || m.selectingQuick || m.confirmingCreate {
+		return nil
+	}
+	return tea.Batch(loadCmd(), tickCmd(), waitTmuxEventCmd())
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if nm, ok := next.(model); ok {
+		nm.scrollToSelection()
+		next = nm
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Refresh triggers are handled before any mode so submenus don't stop
+	// the poll or event loop.
+	switch msg := msg.(type) {
+	case tickMsg:
+		return m, tea.Batch(loadCmd(), tickCmd())
+	case tmuxEventMsg:
+		if msg.client == nil {
+			return m, nil
+		}
+		return m, tea.Batch(loadCmd(), waitTmuxEventCmd())
+	}
+
+	// Handle remote selection mode
+	if m.selectingRemote {
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			return m, nil
+		case tea.KeyMsg:
+			// Handle text input mode for new remote
+			if m.addingNewRemote {
+				switch msg.String() {
+				case "ctrl+c", "esc":
+					m.addingNewRemote = false
+					m.newRemoteInput = ""
+					return m, nil
+				case "enter":
+					if strings.TrimSpace(m.newRemoteInput) != "" {
+						selectedRemoteTarget = strings.TrimSpace(m.newRemoteInput)
+						_ = state.RememberTarget(selectedRemoteTarget)
+						if isLocalRemote() {
+							if _, err := exec.LookPath("tmux"); err != nil {
+								m.status = "Error: tmux is required for local mode"
+								m.addingNewRemote = false
+								m.newRemoteInput = ""
+								return m, nil
+							}
+						}
+						m.selectingRemote = false
+						m.addingNewRemote = false
+						m.newRemoteInput = ""
+						m.preferredWorkspace, _ = state.LastWorkspace(selectedRemoteTarget)
+						m.status = "Loading sessions..."
+						return m, tea.Batch(loadCmd(), tickCmd(), waitTmuxEventCmd())
+					}
+					return m, nil
+				case "backspace":
+					if len(m.newRemoteInput) > 0 {
+						m.newRemoteInput = m.newRemoteInput[:len(m.newRemoteInput)-1]
+					}
+					return m, nil
+				default:
+					// Accept printable characters
+					if len(msg.String()) == 1 {
+						m.newRemoteInput += msg.String()
+					}
+					return m, nil
+				}
+			}
+
+			switch strings.ToLower(msg.String()) {
+			case "ctrl+c", "q", "esc":
+				cleanupSoftPreview(&m)
+				return m, tea.Quit
+			case "up", "k":
+				if m.selectedTarget > 0 {
+					m.selectedTarget--
+				}
+				return m, nil
+			case "down", "j":
+				if m.selectedTarget < len(m.availableTargets)-1 {
+					m.selectedTarget++
+				}
+				return m, nil
+			case "enter":
+				selected := m.availableTargets[m.selectedTarget]
+				// Check if "+ Add new..." was selected
+				if strings.HasPrefix(selected, "+ Add new") {
+					m.addingNewRemote = true
+					m.newRemoteInput = ""
+					return m, nil
+				}
+				selectedRemoteTarget = selected
+				_ = state.RememberTarget(selectedRemoteTarget)
+				if isLocalRemote() {
+					if _, err := exec.LookPath("tmux"); err != nil {
+						m.status = "Error: tmux is required for local mode"
+						return m, nil
+					}
+				}
+				m.selectingRemote = false
+				m.preferredWorkspace, _ = state.LastWorkspace(selectedRemoteTarget)
+				m.activeWorkspace = ""
+				m.activeSession = ""
+				m.status = "Loading sessions..."
+				return m, tea.Batch(loadCmd(), tickCmd(), waitTmuxEventCmd())
+			}
+		}
+		return m, nil
+	}
+
+	if m.selectingNew {
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			return m, nil
+		case tea.KeyMsg:
+			switch strings.ToLower(msg.String()) {
+			case "ctrl+c", "q":
+				cleanupSoftPreview(&m)
+				return m, tea.Quit
+			case "esc":
+				m.selectingNew = false
+				m.status = "Cancelled new session"
+				return m, nil
+			case "up", "k":
+				if m.selectedTemplate > 0 {
+					m.selectedTemplate--
+				}
+				return m, nil
+			case "down", "j":
+				if m.selectedTemplate < len(m.repoTemplates())-1 {
+					m.selectedTemplate++
+				}
+				return m, nil
+			case "enter":
+				templates := m.repoTemplates()
+				if len(templates) == 0 {
+					m.selectingNew = false
+					m.status = "No session templates"
+					return m, nil
+				}
+				tpl := templates[m.selectedTemplate]
+				m.selectingNew = false
+				m.status = "Creating " + tpl.Label + " session..."
+				return m, newSessionCmd(m.newSessionPath(), m.newSessionRepo(), tpl)
+			}
+		}
+		return m, nil
+	}
+
+	if m.selectingQuick {
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			return m, nil
+		case tea.KeyMsg:
+			switch strings.ToLower(msg.String()) {
+			case "ctrl+c", "q", "esc":
+				cleanupSoftPreview(&m)
+				return m, tea.Quit
+			case "up", "k":
+				if m.selectedQuick > 0 {
+					m.selectedQuick--
+				}
+				return m, nil
+			case "down", "j":
+				if m.selectedQuick < len(m.quickCandidates)-1 {
+					m.selectedQuick++
+				}
+				return m, nil
+			case "enter":
+				if len(m.quickCandidates) == 0 {
+					cleanupSoftPreview(&m)
+					return m, tea.Quit
+				}
+				name := m.quickCandidates[m.selectedQuick].Session.Name
+				m.status = "Attaching " + name + "..."
+				cleanupSoftPreview(&m)
+				return m, attachCmd(name)
+			}
+		}
+		return m, nil
+	}
+
+	if m.confirmingCreate {
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			return m, nil
+		case tea.KeyMsg:
+			switch strings.ToLower(msg.String()) {
+			case "ctrl+c", "q":
+				cleanupSoftPreview(&m)
+				return m, tea.Quit
+			case "esc", "n":
+				m.confirmingCreate = false
+				m.status = "Loading sessions..."
+				return m, tea.Batch(loadCmd(), tickCmd(), waitTmuxEventCmd())
+			case "enter", "y":
+				plan := m.quickCreate
+				m.confirmingCreate = false
+				m.status = "Creating " + plan.Template.Label + " session..."
+				return m, createAndAttachCmd(plan.Path, plan.Repo, plan.Template)
+			}
+		}
+		return m, nil
+	}
+
+	if m.renamingSession {
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			return m, nil
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "ctrl+c", "esc":
+				m.renamingSession = false
+				m.renameInput = ""
+				m.status = "Cancelled rename"
+				return m, nil
+			case "enter":
+				name, err := session.RenamedName(m.renamePrefix, m.renameInput)
+				if err != nil {
+					m.status = "Rename failed: " + err.Error()
+					return m, nil
+				}
+				m.renamingSession = false
+				m.renameInput = ""
+				if name == m.renameTarget {
+					m.status = "Name unchanged"
+					return m, nil
+				}
+				m.status = "Renaming " + m.renameTarget + "..."
+				return m, renameSessionCmd(m.renameTarget, name)
+			case "backspace":
+				if len(m.renameInput) > 0 {
+					m.renameInput = m.renameInput[:len(m.renameInput)-1]
+				}
+				return m, nil
+			default:
+				if len(msg.String()) == 1 {
+					m.renameInput += msg.String()
+				}
+				return m, nil
+			}
+		}
+		return m, nil
+	}
+
+	if m.showingHelp {
+		return m.updateHelp(msg)
+	}
+
+	if m.selectingPalette {
+		return m.updatePalette(msg)
+	}
+
+	if m.selectingMenu {
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			m.width = msg.Width
+			m.height = msg.Height
+			return m, nil
+		case tea.KeyMsg:
+			switch strings.ToLower(msg.String()) {
+			case "0":
+				m.selectingMenu = false
+				m.status = ""
+				return m, nil
+			case "up":
+				if m.selectedMenu > 0 {
+					m.selectedMenu--
+				}
+				return m, nil
+			case "down":
+				if m.selectedMenu < len(m.menuItems)-1 {
+					m.selectedMenu++
+				}
+				return m, nil
+			case "enter":
+				if len(m.menuItems) == 0 {
+					m.selectingMenu = false
+					return m, nil
+				}
+				key := m.menuItems[m.selectedMenu].Key
+				m.selectingMenu = false
+				return m.runAction(key)
+			}
+		}
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case loadedMsg:
+		if msg.err != nil {
+			m.status = "Refresh failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.groups = msg.groups
+		m.restoreSelection()
+		if len(m.groups) == 0 {
+			m.status = "No repo entries"
+		} else {
+			m.status = fmt.Sprintf("Loaded %d repo entries", len(m.groups))
+		}
+		for _, g := range m.groups {
+			if g.ConfigErr != nil {
+				m.status = "Ignoring " + config.RepoFile + ": " + g.ConfigErr.Error()
+				break
+			}
+		}
+		return m, previewCmdForSelection(m)
+
+	case actionMsg:
+		m.updateBusy = false
+		if msg.err != nil {
+			m.status = "Action failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.status = msg.status
+		return m, loadCmd()
+
+	case attachResultMsg:
+		if msg.err != nil {
+			m.status = "Attach failed: " + msg.err.Error()
+			if msg.hadControl && tmux.StartControl(tmux.CurrentSession(tmuxClient)) == nil {
+				return m, waitTmuxEventCmd()
+			}
+			return m, nil
+		}
+		cleanupSoftPreview(&m)
+		return m, tea.Quit
+
+	case createdMsg:
+		if msg.err != nil {
+			m.status = "Action failed: " + msg.err.Error()
+			return m, nil
+		}
+		if len(m.groups) > 0 && m.selectedWorkspace >= 0 && m.selectedWorkspace < len(m.groups) {
+			m.activeWorkspace = m.groups[m.selectedWorkspace].Name
+		}
+		m.activeSession = msg.name
+		m.status = msg.status
+		return m, loadCmd()
+
+	case viewCreatedMsg:
+		if msg.err != nil {
+			m.status = "Action failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.multiSelected = nil
+		m.status = fmt.Sprintf("Opened split view (%d panes): %s", msg.count, msg.name)
+		cleanupSoftPreview(&m)
+		return m, attachCmd(msg.name)
+
+	case previewMsg:
+		if m.softAttachPreviewEnabled() {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.previewSession = msg.session
+			m.previewText = "Preview error: " + msg.err.Error()
+			m.previewErr = true
+			return m, nil
+		}
+		m.previewSession = msg.session
+		m.previewText = strings.TrimRight(msg.text, "\n")
+		m.previewErr = false
+		if strings.TrimSpace(m.previewText) == "" {
+			m.previewText = "(no output yet)"
+		}
+		return m, nil
+
+	case softAttachMsg:
+		if msg.err != nil {
+			m.status = "Soft attach failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.previewPane = msg.pane
+		m.previewSession = msg.session
+		return m, nil
+
+	case remoteMsg:
+		if msg.err != nil {
+			m.status = "Remote change failed: " + msg.err.Error()
+			return m, nil
+		}
+		cleanupSoftPreview(&m)
+		selectedRemoteTarget = msg.target
+		_ = state.RememberTarget(selectedRemoteTarget)
+		tmuxClient = tmux.ForTarget(remoteTarget())
+		m.preferredWorkspace, _ = state.LastWorkspace(selectedRemoteTarget)
+		m.activeWorkspace = ""
+		m.activeSession = ""
+		m.status = "Switched to remote: " + remoteTarget()
+		return m, loadCmd()
+
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
+
+	case tea.KeyMsg:
+		key := strings.ToLower(msg.String())
+		if key == "ctrl+c" {
+			cleanupSoftPreview(&m)
+			return m, tea.Quit
+		}
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			idx := int(key[0] - '1')
+			repos := m.repoOrder()
+			if idx < len(repos) {
+				m.selectedWorkspace = repos[idx]
+				m.selectedSession = defaultSessionIndex(m.visibleSessions(m.selectedWorkspace))
+				m.captureActive()
+				return m, previewCmdForSelection(m)
+			}
+			return m, nil
+		}
+		return m.runAction(m.keymap().Action(key))
+	}
+
+	return m, nil
+}
+
+// runAction does what a key bound to action does in the main view; the
+// command palette runs its entries through here too.
+func (m model) runAction(action string) (tea.Model, tea.Cmd) {
+	switch action {
+	case "quit":
+		cleanupSoftPreview(&m)
+		return m, tea.Quit
+	case "help":
+		m.showingHelp = true
+		return m, nil
+	case "palette":
+		m.selectingPalette = true
+		m.paletteQuery = ""
+		m.selectedPalette = 0
+		return m, nil
+	case "update":
+		if m.updateBusy {
+			return m, nil
+		}
+		m.updateBusy = true
+		m.status = "Updating from origin/main..."
+		return m, updateCmd()
+	case "export":
+		m.status = "Saving snapshot..."
+		return m, exportCmd()
+	case "destroy":
+		sel, ok := m.selectedSessionInfo()
+		if !ok {
+			return m, nil
+		}
+		m.status = "Destroying " + sel.Name + "..."
+		return m, killSessionCmd(sel.Name)
+	case "rename":
+		m.startRename()
+		return m, nil
+	case "restart":
+		sel, ok := m.selectedSessionInfo()
+		if !ok {
+			return m, nil
+		}
+		m.status = "Restarting " + sel.Name + "..."
+		return m, restartSessionCmd(m.groups[m.selectedWorkspace].Repo, sel, m.repoTemplates())
+	case "neovim":
+		return m, spawnAndAttachCmd(m, "neovim")
+	case "new":
+		m.selectingNew = true
+		m.selectedTemplate = 0
+		m.status = "Choose new session command"
+		return m, nil
+	case "refresh":
+		m.status = "Refreshing..."
+		return m, loadCmd()
+	case "fold":
+		m.toggleCollapsed()
+		return m, previewCmdForSelection(m)
+	case "pin":
+		m.togglePinned()
+		return m, nil
+	case "hide-empty":
+		m.toggleHideEmpty()
+		return m, previewCmdForSelection(m)
+	case "menu":
+		m.menuItems = buildMenuItems(m)
+		m.selectedMenu = 0
+		m.selectingMenu = true
+		m.status = "Menu"
+		return m, nil
+	case "prev-repo":
+		if m.shiftRepo(-1) {
+			return m, previewCmdForSelection(m)
+		}
+		return m, nil
+	case "next-repo":
+		if m.shiftRepo(1) {
+			return m, previewCmdForSelection(m)
+		}
+		return m, nil
+	case "up":
+		if m.shiftVertical(-1) {
+			return m, previewCmdForSelection(m)
+		}
+		return m, nil
+	case "down":
+		if m.shiftVertical(1) {
+			return m, previewCmdForSelection(m)
+		}
+		return m, nil
+	case "page-up", "page-down", "top", "bottom":
+		var delta int
+		switch action {
+		case "page-up":
+			delta = -m.pageSize()
+		case "page-down":
+			delta = m.pageSize()
+		case "top":
+			delta = -len(m.listRows())
+		case "bottom":
+			delta = len(m.listRows())
+		}
+		if m.pageSelection(delta) {
+			return m, previewCmdForSelection(m)
+		}
+		return m, nil
+	case "attach":
+		return m.enterSelection()
+	case "opencode":
+		return m, spawnAndAttachCmd(m, "opencode")
+	case "lazygit":
+		return m, spawnAndAttachCmd(m, "lazygit")
+	case "claude":
+		return m, spawnAndAttachCmd(m, "claude-full")
+	case "default":
+		return m, spawnDefaultCmd(m)
+	}
+	return m, nil
+}
+
+// enterSelection attaches the selected session, or creates one from the
+// default template on a repo without sessions.
+func (m model) enterSelection() (tea.Model, tea.Cmd) {
+	if len(m.currentSessions()) == 0 && !config.EmptyEnterJumps() {
+		m.status = "Creating " + m.repoConfig().DefaultTemplate(m.repoTemplates()).Label + " session..."
+		return m, spawnDefaultCmd(m)
+	}
+	sel, ok := m.attachableSession()
+	if !ok {
+		m.status = "No attachable session"
+		return m, nil
+	}
+	m.status = "Attaching " + sel.Name + "..."
+	cleanupSoftPreview(&m)
+	return m, attachCmd(sel.Name)
+}
+
+func (m model) View() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Title)).Render("echoshell")
+
+	// Remote selection view
+	if m.selectingRemote {
+		// Text input mode for new remote
+		if m.addingNewRemote {
+			help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("enter: confirm  esc: cancel")
+			heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Enter new remote (e.g., user@host):")
+
+			cursor := lipgloss.NewStyle().Foreground(color(theme.Selected)).Render("▊")
+			inputLine := m.newRemoteInput + cursor
+			inputStyle := lipgloss.NewStyle().Foreground(color(theme.Status)).Padding(0, 1)
+
+			lines := []string{
+				heading,
+				"",
+				inputStyle.Render(inputLine),
+			}
+
+			box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+			return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+		}
+
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("j/k or ↑/↓: navigate  enter: select  q: quit")
+		heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Select Remote Target:")
+
+		sel := selectedStyle().Padding(0, 1)
+		norm := lipgloss.NewStyle().Padding(0, 1)
+
+		lines := []string{heading, ""}
+		for i, target := range m.availableTargets {
+			line := target
+			if i == m.selectedTarget {
+				lines = append(lines, sel.Render(line))
+			} else {
+				lines = append(lines, norm.Render(line))
+			}
+		}
+
+		box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+	}
+
+	if m.renamingSession {
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("enter: rename  esc: cancel")
+		heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Rename " + m.renameTarget + ":")
+
+		cursor := lipgloss.NewStyle().Foreground(color(theme.Selected)).Render("▊")
+		prefix := lipgloss.NewStyle().Foreground(color(theme.Help)).Render(m.renamePrefix)
+		inputStyle := lipgloss.NewStyle().Foreground(color(theme.Status)).Padding(0, 1)
+
+		lines := []string{
+			heading,
+			"",
+			inputStyle.Render(prefix + m.renameInput + cursor),
+		}
+
+		box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+	}
+
+	if m.selectingPalette {
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("type to filter  up/down: navigate  enter: run  esc: close")
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", m.renderPalette(), "", help)
+	}
+
+	if m.showingHelp {
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("any key: close  keys file: " + config.KeysFile)
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", m.renderHelp(), "", help)
+	}
+
+	if m.selectingMenu {
+		heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Menu")
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("up/down: navigate  enter: run  0: close")
+		sel := selectedStyle().Padding(0, 1)
+		norm := lipgloss.NewStyle().Padding(0, 1)
+
+		lines := []string{heading, ""}
+		for i, it := range m.menuItems {
+			if i == m.selectedMenu {
+				lines = append(lines, sel.Render(it.Label))
+			} else {
+				lines = append(lines, norm.Render(it.Label))
+			}
+		}
+		box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+	}
+
+	if m.selectingNew {
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("j/k or ↑/↓: navigate  enter: create  esc: cancel")
+		heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("New Session Command:")
+
+		sel := selectedStyle().Padding(0, 1)
+		norm := lipgloss.NewStyle().Padding(0, 1)
+
+		lines := []string{heading, ""}
+		for i, t := range m.repoTemplates() {
+			line := t.Label
+			if i == m.selectedTemplate {
+				lines = append(lines, sel.Render(line))
+			} else {
+				lines = append(lines, norm.Render(line))
+			}
+		}
+
+		box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+	}
+
+	if m.confirmingCreate {
+		heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("No session matches: " + m.quickQuery)
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("enter/y: create and attach  esc/n: open picker  q: quit")
+		norm := lipgloss.NewStyle().Padding(0, 1)
+		plan := m.quickCreate
+		lines := []string{
+			heading,
+			"",
+			norm.Render(fmt.Sprintf("Create %s session in %s?", plan.Template.Label, plan.Repo)),
+			norm.Render(plan.Path),
+		}
+		box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+	}
+
+	if m.selectingQuick {
+		heading := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Quick Attach: " + m.quickQuery)
+		help := lipgloss.NewStyle().Foreground(color(theme.Help)).Render("j/k or ↑/↓: navigate  enter: attach  esc: quit")
+		sel := selectedStyle().Padding(0, 1)
+		norm := lipgloss.NewStyle().Padding(0, 1)
+		lines := []string{heading, ""}
+		for i, c := range m.quickCandidates {
+			line := fmt.Sprintf("%s  (%s/%s)", c.Session.Name, c.Workspace, c.Repo)
+			if i == m.selectedQuick {
+				lines = append(lines, sel.Render(line))
+			} else {
+				lines = append(lines, norm.Render(line))
+			}
+		}
+		box := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2).Render(strings.Join(lines, "\n"))
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", box, "", help)
+	}
+
+	helpNav := lipgloss.NewStyle().Foreground(color(theme.Help)).Render(m.helpLine())
+	help := helpNav
+	status := lipgloss.NewStyle().Foreground(color(theme.Status)).Render("status: " + m.status)
+
+	if len(m.groups) == 0 {
+		empty := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).Padding(1, 2).Render("No sessions")
+		return lipgloss.JoinVertical(lipgloss.Left, title, empty, status, help)
+	}
+
+	leftW := 30
+	if m.width > 0 {
+		leftW = max(34, m.width-4)
+	}
+	left := m.renderWorkspaces(leftW, m.bodyHeight())
+	body := left
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, body, status, help)
+}
+
+func (m model) renderWorkspaces(width, height int) string {
+	box := lipgloss.NewStyle().Width(width).Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(1, 2)
+	if height > 0 {
+		box = box.Height(height)
+	}
+	title := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Heading)).Render("Repos")
+
+	repoSel := selectedStyle().Padding(0, 1)
+	sessSel := lipgloss.NewStyle().Bold(true).Foreground(color(theme.Selected)).Padding(0, 1)
+	sessNorm := lipgloss.NewStyle().Foreground(color(theme.Text)).Padding(0, 1)
+
+	rows := m.listRows()
+	start, end := 0, len(rows)
+	if height > 0 {
+		n := listLines(height)
+		start = scrollOffset(m.listOffset, m.selectedRow(rows), len(rows), n)
+		end = min(len(rows), start+n)
+	}
+	if pos := positionIndicator(start, end, len(rows)); pos != "" {
+		title += lipgloss.NewStyle().Foreground(color(theme.Help)).Render("  " + pos)
+	}
+	lines := []string{title, ""}
+	for _, r := range rows[start:end] {
+		if r.repo < 0 {
+			lines = append(lines, "")
+			continue
+		}
+		i, g := r.repo, m.groups[r.repo]
+		if r.session < 0 {
+			markerStyle := lipgloss.NewStyle().Foreground(color(theme.RepoColor(g.Repo))).Bold(true)
+			marker := " "
+			if i == m.selectedWorkspace {
+				marker = "|"
+			}
+			repoLine := fmt.Sprintf("%s %s%s (%d)%s", markerStyle.Render(marker), foldMark(m.isCollapsed(g.Name), len(g.Sessions)), g.Repo, len(g.Sessions), pinMark(m.isPinned(g.Name)))
+			repoColor := lipgloss.NewStyle().Foreground(color(theme.RepoColor(g.Repo))).Padding(0, 1)
+			if i == m.selectedWorkspace {
+				lines = append(lines, repoSel.Render(repoLine))
+			} else {
+				lines = append(lines, repoColor.Render(repoLine))
+			}
+			continue
+		}
+
+		si, s := r.session, g.Sessions[r.session]
+		att := " "
+		if s.Attached {
+			att = "*"
+		}
+		name := session.TrimRepoPrefix(g.Repo, s.Name)
+		mark := " "
+		if i == m.selectedWorkspace && si == m.selectedSession {
+			if m.previewErr {
+				mark = "!"
+			} else {
+				mark = "."
+			}
+		}
+		sLine := fmt.Sprintf("  %s %s %s%s%s", mark, att, name, templateTag(name, s.Meta)+branchTag(s), attributionTag(s.Attribution))
+		if i == m.selectedWorkspace && si == m.selectedSession {
+			lines = append(lines, sessSel.Render(sLine))
+		} else {
+			lines = append(lines, sessNorm.Render(sLine))
+		}
+	}
+
+	return box.Render(strings.Join(lines, "\n"))
+}
+
+// foldMark shows whether a repo's sessions are folded away.
+func foldMark(collapsed bool, sessions int) string {
+	switch {
+	case sessions == 0:
+		return ""
+	case collapsed:
+		return "▸ "
+	}
+	return "▾ "
+}
+
+func pinMark(pinned bool) string {
+	if pinned {
+		return " ★"
+	}
+	return ""
+}
+
+// templateTag names the template a session was made from when its name no
+// longer says so, e.g. after a rename.
+func templateTag(name string, meta discovery.Meta) string {
+	token := session.Sanitize(meta.Template)
+	if token == "" || strings.Contains(name, token) {
+		return ""
+	}
+	return " [" + meta.Template + "]"
+}
+
+// branchTag flags a session named after a branch whose first pane has since
+// moved to another one.
+func branchTag(s discovery.SessionInfo) string {
+	if s.Meta.Branch == "" || s.Branch == "" || s.Branch == s.Meta.Branch {
+		return ""
+	}
+	return " [now on " + s.Branch + "]"
+}
+
+// attributionTag tells which rule grouped a session under its repo.
+func attributionTag(rule string) string {
+	switch rule {
+	case discovery.AttributedPinned:
+		return "  (pinned)"
+	case discovery.AttributedStartDir:
+		return "  (start dir)"
+	case discovery.AttributedPanes:
+		return "  (panes)"
+	}
+	return ""
+}
+
+func defaultSessionIndex(sessions []discovery.SessionInfo) int {
+	if len(sessions) == 0 {
+		return -1
+	}
+	return 0
+}
+
+func buildMenuItems(m model) []menuItem {
+	items := []menuItem{}
+	if _, ok := m.selectedSessionInfo(); ok {
+		items = append(items, menuItem{Label: "Attach selected", Key: "attach"})
+		items = append(items, menuItem{Label: "Destroy selected", Key: "destroy"})
+		items = append(items, menuItem{Label: "Rename selected", Key: "rename"})
+		items = append(items, menuItem{Label: "Restart selected", Key: "restart"})
+	}
+	items = append(items, menuItem{Label: "Refresh", Key: "refresh"})
+	items = append(items, menuItem{Label: "Update", Key: "update"})
+	items = append(items, menuItem{Label: "Quit", Key: "quit"})
+	return items
+}
+
+func spawnAndAttachCmd(m model, templateName string) tea.Cmd {
+	return createAndAttachCmd(m.newSessionPath(), m.newSessionRepo(), templateByName(m.repoTemplates(), templateName))
+}
+
+// spawnDefaultCmd creates a session from the selected repo's default template
+// and attaches it.
+func spawnDefaultCmd(m model) tea.Cmd {
+	tpl := m.repoConfig().DefaultTemplate(m.repoTemplates())
+	return createAndAttachCmd(m.newSessionPath(), m.newSessionRepo(), tpl)
+}
+
+// repoConfig is the selected repo's .echoshell.toml.
+func (m model) repoConfig() config.Repo {
+	if len(m.groups) > 0 && m.selectedWorkspace >= 0 && m.selectedWorkspace < len(m.groups) {
+		return m.groups[m.selectedWorkspace].Config
+	}
+	return config.Repo{}
+}
+
+// repoTemplates are the templates offered for the selected repo.
+func (m model) repoTemplates() []config.Template {
+	return m.repoConfig().Resolve(m.newTemplates)
+}
+
+// templateByName finds the template a hotkey spawns; an unknown name yields a
+// plain shell session under that name.
+func templateByName(templates []config.Template, name string) config.Template {
+	for _, t := range templates {
+		if t.Name == name {
+			return t
+		}
+	}
+	return config.Template{Label: name, Name: name}
+}
+
+func createAndAttachCmd(path, repo string, t config.Template) tea.Cmd {
+	return func() tea.Msg {
+		name, err := session.Create(tmuxClient, path, repo, t)
+		if err != nil {
+			return viewCreatedMsg{err: err}
+		}
+		return viewCreatedMsg{name: name, count: 1}
+	}
+}
+
+func (m model) currentWorkspaceName() string {
+	if len(m.groups) == 0 || m.selectedWorkspace < 0 || m.selectedWorkspace >= len(m.groups) {
+		return "root"
+	}
+	return discovery.WorkspaceName(m.groups[m.selectedWorkspace])
+}
+
+func (m model) workspaceList() []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, g := range m.groups {
+		ws := discovery.WorkspaceName(g)
+		if ws == "" {
+			ws = "root"
+		}
+		if !seen[ws] {
+			seen[ws] = true
+			out = append(out, ws)
+		}
+	}
+	if len(out) == 0 {
+		return []string{"root"}
+	}
+	return out
+}
+
+func (m model) workspaceTotalSessions(workspace string) int {
+	ws := strings.TrimSpace(workspace)
+	if ws == "" {
+		ws = "root"
+	}
+	total := 0
+	for _, g := range m.groups {
+		if discovery.WorkspaceName(g) != ws {
+			continue
+		}
+		total += len(g.Sessions)
+	}
+	return total
+}
+
+func (m model) repoIndexesForWorkspace(workspace string) []int {
+	ws := strings.TrimSpace(workspace)
+	if ws == "" {
+		ws = "root"
+	}
+	idxs := make([]int, 0, len(m.groups))
+	for i, g := range m.groups {
+		if discovery.WorkspaceName(g) == ws {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+// repoOrder lists the group indexes in display order: pinned repos first,
+// without empty repos while those are hidden.
+func (m model) repoOrder() []int {
+	if len(m.groups) == 0 {
+		return nil
+	}
+	pinned := []int{}
+	rest := make([]int, 0, len(m.groups))
+	for i, g := range m.groups {
+		switch {
+		case m.isPinned(g.Name):
+			pinned = append(pinned, i)
+		case m.view.HideEmpty && len(g.Sessions) == 0:
+		default:
+			rest = append(rest, i)
+		}
+	}
+	return append(pinned, rest...)
+}
+
+func (m *model) shiftRepo(direction int) bool {
+	order := m.repoOrder()
+	if len(order) == 0 {
+		return false
+	}
+	curPos := 0
+	for i, idx := range order {
+		if idx == m.selectedWorkspace {
+			curPos = i
+			break
+		}
+	}
+	next := (curPos + direction + len(order)) % len(order)
+	m.selectedWorkspace = order[next]
+	m.selectedSession = defaultSessionIndex(m.visibleSessions(m.selectedWorkspace))
+	m.captureActive()
+	return true
+}
+
+func (m *model) shiftSession(direction int) bool {
+	order := m.repoOrder()
+	if len(order) == 0 {
+		return false
+	}
+
+	// Find current repo position in order.
+	curRepoPos := 0
+	for i, idx := range order {
+		if idx == m.selectedWorkspace {
+			curRepoPos = i
+			break
+		}
+	}
+
+	// Fast path: within current repo sessions.
+	curSessions := m.visibleSessions(m.selectedWorkspace)
+	if m.selectedSession < 0 {
+		if len(curSessions) == 0 {
+			// No sessions here; fall through to find another repo.
+		} else {
+			if direction > 0 {
+				m.selectedSession = 0
+			} else {
+				m.selectedSession = len(curSessions) - 1
+			}
+			m.captureActive()
+			return true
+		}
+	}
+	if m.selectedSession >= 0 && m.selectedSession < len(curSessions) {
+		nextSess := m.selectedSession + direction
+		if nextSess >= 0 && nextSess < len(curSessions) {
+			m.selectedSession = nextSess
+			m.captureActive()
+			return true
+		}
+	}
+
+	// Boundary: move to next/prev repo that has sessions.
+	pos := curRepoPos
+	for step := 0; step < len(order); step++ {
+		pos = (pos + direction + len(order)) % len(order)
+		repoIdx := order[pos]
+		sessions := m.visibleSessions(repoIdx)
+		if len(sessions) == 0 {
+			continue
+		}
+		m.selectedWorkspace = repoIdx
+		if direction > 0 {
+			m.selectedSession = 0
+		} else {
+			m.selectedSession = len(sessions) - 1
+		}
+		m.captureActive()
+		return true
+	}
+
+	return false
+}
+
+func (m *model) shiftVertical(direction int) bool {
+	order := m.repoOrder()
+	if len(order) == 0 {
+		return false
+	}
+
+	type pos struct {
+		repo int
+		sess int
+	}
+
+	positions := make([]pos, 0, len(order)*2)
+	for _, repoIdx := range order {
+		positions = append(positions, pos{repo: repoIdx, sess: -1})
+		for si := range m.visibleSessions(repoIdx) {
+			positions = append(positions, pos{repo: repoIdx, sess: si})
+		}
+	}
+	if len(positions) == 0 {
+		return false
+	}
+
+	cur := 0
+	for i, p := range positions {
+		if p.repo == m.selectedWorkspace && p.sess == m.selectedSession {
+			cur = i
+			break
+		}
+	}
+
+	next := (cur + direction + len(positions)) % len(positions)
+	m.selectedWorkspace = positions[next].repo
+	m.selectedSession = positions[next].sess
+	m.captureActive()
+	return true
+}
+
+func (m model) renderSessions(width int) string {
+	box := lipgloss.NewStyle().Width(width).Border(lipgloss.NormalBorder()).BorderForeground(color(theme.Border)).Padding(0, 1)
+	previewRaw := m.previewText
+	if strings.TrimSpace(previewRaw) == "" {
+		previewRaw = "(select a session)"
+	}
+	previewLines := strings.Split(previewRaw, "\n")
+	if len(previewLines) == 1 && strings.TrimSpace(previewLines[0]) == "" {
+		previewLines = []string{"(select a session)"}
+	}
+	if len(previewLines) > maxPreviewLines {
+		previewLines = previewLines[len(previewLines)-maxPreviewLines:]
+		previewLines[0] = "..."
+	}
+	for len(previewLines) < maxPreviewLines {
+		previewLines = append(previewLines, "")
+	}
+	previewBody := strings.Join(previewLines, "\n")
+	previewHeader := lipgloss.NewStyle().Foreground(color(theme.Preview)).Background(color(theme.PreviewBg)).Padding(0, 1).Render("● ● ●  tmux preview")
+	previewPane := lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(theme.PreviewBorder)).Padding(0, 1).Render(previewBody)
+	previewBox := lipgloss.JoinVertical(lipgloss.Left, previewHeader, previewPane)
+
+	return box.Render(previewBox)
+}
+
+func (m *model) restoreSelection() {
+	if len(m.groups) == 0 {
+		m.selectedWorkspace = 0
+		m.selectedSession = 0
+		return
+	}
+
+	if m.activeWorkspace != "" {
+		for i, g := range m.groups {
+			if g.Name == m.activeWorkspace {
+				m.selectedWorkspace = i
+				break
+			}
+		}
+	}
+
+	if m.activeWorkspace == "" && strings.TrimSpace(m.preferredWorkspace) != "" {
+		for i, g := range m.groups {
+			if discovery.WorkspaceName(g) == strings.TrimSpace(m.preferredWorkspace) {
+				m.selectedWorkspace = i
+				break
+			}
+		}
+	}
+
+	if m.selectedWorkspace < 0 {
+		m.selectedWorkspace = 0
+	}
+	if m.selectedWorkspace >= len(m.groups) {
+		m.selectedWorkspace = len(m.groups) - 1
+	}
+	m.selectVisibleRepo()
+
+	cur := m.visibleSessions(m.selectedWorkspace)
+	if len(cur) == 0 {
+		m.selectedSession = -1
+		m.captureActive()
+		return
+	}
+
+	if m.activeSession == "" && m.selectedSession == -1 {
+		m.captureActive()
+		return
+	}
+
+	if m.activeSession != "" {
+		for i, s := range cur {
+			if s.Name == m.activeSession {
+				m.selectedSession = i
+				break
+			}
+		}
+	}
+
+	if m.selectedSession < 0 {
+		m.selectedSession = 0
+	}
+	if m.selectedSession >= len(cur) {
+		m.selectedSession = len(cur) - 1
+	}
+	m.captureActive()
+}
+
+func (m *model) captureActive() {
+	if len(m.groups) == 0 || m.selectedWorkspace < 0 || m.selectedWorkspace >= len(m.groups) {
+		m.activeWorkspace = ""
+		m.activeSession = ""
+		return
+	}
+	ws := strings.TrimSpace(m.groups[m.selectedWorkspace].Workspace)
+	if ws == "" {
+		ws = "root"
+	}
+	if ws != m.preferredWorkspace {
+		m.preferredWorkspace = ws
+		_ = state.RememberWorkspace(remoteTarget(), ws)
+	}
+	m.activeWorkspace = m.groups[m.selectedWorkspace].Name
+	cur := m.currentSessions()
+	if len(cur) == 0 || m.selectedSession < 0 || m.selectedSession >= len(cur) {
+		m.activeSession = ""
+		return
+	}
+	m.activeSession = cur[m.selectedSession].Name
+}
+
+func (m model) currentSessions() []discovery.SessionInfo {
+	if len(m.groups) == 0 || m.selectedWorkspace < 0 || m.selectedWorkspace >= len(m.groups) {
+		return nil
+	}
+	return m.groups[m.selectedWorkspace].Sessions
+}
+
+func (m model) selectedSessionInfo() (discovery.SessionInfo, bool) {
+	cur := m.currentSessions()
+	if len(cur) == 0 || m.selectedSession < 0 || m.selectedSession >= len(cur) {
+		return discovery.SessionInfo{}, false
+	}
+	return cur[m.selectedSession], true
+}
+
+func (m *model) selectFirstWorkspaceWithSessions() bool {
+	for i := range m.groups {
+		if len(m.groups[i].Sessions) == 0 {
+			continue
+		}
+		m.selectedWorkspace = i
+		m.selectedSession = defaultSessionIndex(m.groups[i].Sessions)
+		return m.selectedSession >= 0
+	}
+	return false
+}
+
+func (m *model) attachableSession() (discovery.SessionInfo, bool) {
+	if sel, ok := m.selectedSessionInfo(); ok {
+		return sel, true
+	}
+
+	cur := m.currentSessions()
+	if len(cur) > 0 {
+		m.selectedSession = defaultSessionIndex(cur)
+		if sel, ok := m.selectedSessionInfo(); ok {
+			m.captureActive()
+			return sel, true
+		}
+	}
+
+	if !m.selectFirstWorkspaceWithSessions() {
+		return discovery.SessionInfo{}, false
+	}
+	if sel, ok := m.selectedSessionInfo(); ok {
+		m.captureActive()
+		return sel, true
+	}
+	return discovery.SessionInfo{}, false
+}
+
+func loadCmd() tea.Cmd {
+	return func() tea.Msg {
+		groups, err := groupedSessions()
+		return loadedMsg{groups: groups, err: err}
+	}
+}
+
+func newSessionCmd(path, repo string, t config.Template) tea.Cmd {
+	return func() tea.Msg {
+		name, err := session.Create(tmuxClient, path, repo, t)
+		if err != nil {
+			return createdMsg{err: err}
+		}
+		return createdMsg{name: name, status: "Created " + name}
+	}
+}
+
+// exportCmd saves a snapshot of every session, including the one the
+// picker runs in; see session.Save.
+func exportCmd() tea.Cmd {
+	return func() tea.Msg {
+		groups, err := discovery.GroupAllSessions(tmuxClient, remoteTarget())
+		if err != nil {
+			return actionMsg{err: err}
+		}
+		n, path, err := session.Save(tmuxClient, groups)
+		if err != nil {
+			return actionMsg{err: err}
+		}
+		return actionMsg{status: fmt.Sprintf("Saved %d sessions to %s", n, path)}
+	}
+}
+
+func (m *model) startRename() {
+	sel, ok := m.selectedSessionInfo()
+	if !ok {
+		m.status = "No session selected"
+		return
+	}
+	g := m.groups[m.selectedWorkspace]
+	m.renamingSession = true
+	m.renameTarget = sel.Name
+	m.renamePrefix = session.RenamePrefix(g, sel.Name)
+	m.renameInput = strings.TrimPrefix(sel.Name, m.renamePrefix)
+	m.status = "Rename " + sel.Name
+}
+
+func renameSessionCmd(oldName, newName string) tea.Cmd {
+	return func() tea.Msg {
+		if err := tmuxClient.RenameSession(oldName, newName); err != nil {
+			return createdMsg{err: err}
+		}
+		return createdMsg{name: newName, status: "Renamed " + oldName + " to " + newName}
+	}
+}
+
+func restartSessionCmd(repo string, s discovery.SessionInfo, templates []config.Template) tea.Cmd {
+	return func() tea.Msg {
+		t, ok := session.SessionTemplate(repo, s, templates)
+		if !ok {
+			return actionMsg{err: fmt.Errorf("don't know which template started %s", s.Name)}
+		}
+		if err := session.Restart(tmuxClient, s.Name, t); err != nil {
+			return actionMsg{err: err}
+		}
+		return actionMsg{status: "Restarted " + s.Name}
+	}
+}
+
+func killSessionCmd(name string) tea.Cmd {
+	return func() tea.Msg {
+		if err := session.Kill(tmuxClient, remoteTarget(), name); err != nil {
+			return actionMsg{err: err}
+		}
+		return actionMsg{status: "Destroyed " + name}
+	}
+}
+
+func (m model) newSessionPath() string {
+	if len(m.groups) > 0 && m.selectedWorkspace >= 0 && m.selectedWorkspace < len(m.groups) {
+		if p := strings.TrimSpace(m.groups[m.selectedWorkspace].Path); p != "" {
+			return p
+		}
+	}
+	if sel, ok := m.selectedSessionInfo(); ok && strings.TrimSpace(sel.Workdir) != "" {
+		return sel.Workdir
+	}
+	return "/"
+}
+
+func (m model) newSessionRepo() string {
+	if len(m.groups) > 0 && m.selectedWorkspace >= 0 && m.selectedWorkspace < len(m.groups) {
+		repo := strings.TrimSpace(m.groups[m.selectedWorkspace].Repo)
+		if repo != "" {
+			return repo
+		}
+	}
+	if sel, ok := m.selectedSessionInfo(); ok {
+		if p := strings.TrimSpace(sel.Workdir); p != "" {
+			return filepath.Base(p)
+		}
+	}
+	return "sh"
+}
+
+func tickCmd() tea.Cmd {
+	interval := refreshInterval
+	if tmux.ActiveControl() != nil {
+		// Control-mode notifications drive refreshes; polling only catches
+		// changes tmux does not announce, like a pane's current path.
+		interval = controlRefreshInterval
+	}
+	return tea.Tick(interval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func attachCmd(session string) tea.Cmd {
+	// The control client is attached to our own session too; drop it so
+	// switch-client can't pick it instead of the user's terminal. It has to
+	// go before the attach runs, so a failed attach restarts it.
+	hadControl := tmux.ActiveControl() != nil
+	tmux.StopControl()
+	return tea.ExecProcess(tmux.AttachCmd(session), func(err error) tea.Msg {
+		return attachResultMsg{err: err, hadControl: hadControl}
+	})
+}
+
+func cycleRemoteCmd() tea.Cmd {
+	return func() tea.Msg {
+		targets, err := state.Targets()
+		if err != nil || len(targets) == 0 {
+			targets = []string{"local"}
+		}
+
+		// Find current target in list
+		current := remoteTarget()
+		nextIdx := 0
+		for i, t := range targets {
+			if t == current {
+				nextIdx = (i + 1) % len(targets)
+				break
+			}
+		}
+
+		return remoteMsg{target: targets[nextIdx]}
+	}
+}
+
+func waitTmuxEventCmd() tea.Cmd {
+	c := tmux.ActiveControl()
+	if c == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		select {
+		case <-c.Events():
+			return tmuxEventMsg{client: c}
+		case <-c.Done():
+			return tmuxEventMsg{}
+		}
+	}
+}
+
+func remoteTarget() string {
+	return "local"
+}
+
+func isLocalRemote() bool {
+	return true
+}
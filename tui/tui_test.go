@@ -0,0 +1,479 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"echoshell/config"
+	"echoshell/discovery"
+	"echoshell/state"
+	"echoshell/tmux/tmuxtest"
+)
+
+func TestAttachableSessionFromRepoRow(t *testing.T) {
+	m := model{
+		groups: []discovery.WorkspaceGroup{
+			{Repo: "r", Sessions: []discovery.SessionInfo{{Name: "r-shell-1"}, {Name: "r-shell-2"}}},
+		},
+		selectedWorkspace: 0,
+		selectedSession:   -1,
+	}
+
+	sel, ok := m.attachableSession()
+	if !ok {
+		t.Fatalf("expected attachable session")
+	}
+	if sel.Name != "r-shell-1" {
+		t.Fatalf("expected first session, got %q", sel.Name)
+	}
+	if m.selectedSession != 0 {
+		t.Fatalf("expected selectedSession to be 0, got %d", m.selectedSession)
+	}
+}
+
+func TestAttachableSessionFallsBackToFirstWorkspaceWithSessions(t *testing.T) {
+	m := model{
+		groups: []discovery.WorkspaceGroup{
+			{Repo: "root", Sessions: nil},
+			{Repo: "app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}}},
+		},
+		selectedWorkspace: 0,
+		selectedSession:   -1,
+	}
+
+	sel, ok := m.attachableSession()
+	if !ok {
+		t.Fatalf("expected attachable session")
+	}
+	if sel.Name != "app-shell-1" {
+		t.Fatalf("expected fallback session app-shell-1, got %q", sel.Name)
+	}
+	if m.selectedWorkspace != 1 {
+		t.Fatalf("expected selectedWorkspace to move to 1, got %d", m.selectedWorkspace)
+	}
+	if m.selectedSession != 0 {
+		t.Fatalf("expected selectedSession to be 0, got %d", m.selectedSession)
+	}
+}
+
+func TestRestoreSelectionKeepsEmptyWorkspaceRow(t *testing.T) {
+	m := model{
+		groups: []discovery.WorkspaceGroup{
+			{Workspace: "root", Name: "root", Repo: "root", Sessions: nil},
+			{Workspace: "git", Name: "git/app", Repo: "app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}}},
+		},
+		selectedWorkspace: 0,
+		selectedSession:   -1,
+		activeWorkspace:   "root",
+	}
+
+	m.restoreSelection()
+
+	if m.selectedWorkspace != 0 {
+		t.Fatalf("expected selectedWorkspace to stay on 0, got %d", m.selectedWorkspace)
+	}
+	if m.selectedSession != -1 {
+		t.Fatalf("expected selectedSession to stay on repo row (-1), got %d", m.selectedSession)
+	}
+}
+
+func TestRestoreSelectionKeepsRepoRowOnWorkspaceWithSessions(t *testing.T) {
+	m := model{
+		groups: []discovery.WorkspaceGroup{
+			{Workspace: "git", Name: "git/app", Repo: "app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}, {Name: "app-shell-2"}}},
+		},
+		selectedWorkspace: 0,
+		selectedSession:   -1,
+		activeWorkspace:   "git/app",
+	}
+
+	m.restoreSelection()
+
+	if m.selectedWorkspace != 0 {
+		t.Fatalf("expected selectedWorkspace to stay on 0, got %d", m.selectedWorkspace)
+	}
+	if m.selectedSession != -1 {
+		t.Fatalf("expected selectedSession to stay on repo row (-1), got %d", m.selectedSession)
+	}
+}
+
+func TestSoftAttachPaneCommandUsesReadOnlyAttach(t *testing.T) {
+	cmd := softAttachPaneCommand("my-session")
+	if !strings.Contains(cmd, "TMUX=") {
+		t.Fatalf("preview command should clear TMUX: %q", cmd)
+	}
+	if !strings.Contains(cmd, "attach-session") {
+		t.Fatalf("preview command should attach session: %q", cmd)
+	}
+	if !strings.Contains(cmd, "-r") {
+		t.Fatalf("preview command should be read-only: %q", cmd)
+	}
+}
+
+func useFakeTmux(t *testing.T, f *tmuxtest.Fake, groups []discovery.WorkspaceGroup) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TMUX", "")
+	t.Setenv("TMUX_PANE", "")
+
+	prevClient := tmuxClient
+	tmuxClient = f
+	discovery.SetCache("local", groups)
+	t.Cleanup(func() {
+		tmuxClient = prevClient
+		discovery.SetCache("local", nil)
+	})
+}
+
+func keyMsg(k string) tea.KeyMsg {
+	switch k {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case "ctrl+n":
+		return tea.KeyMsg{Type: tea.KeyCtrlN}
+	case "ctrl+p":
+		return tea.KeyMsg{Type: tea.KeyCtrlP}
+	case "pgdown":
+		return tea.KeyMsg{Type: tea.KeyPgDown}
+	case "home":
+		return tea.KeyMsg{Type: tea.KeyHome}
+	case "end":
+		return tea.KeyMsg{Type: tea.KeyEnd}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+// drive feeds msg into the model and keeps running the returned commands
+// until the loop settles. Batches (ticks) and exec commands (attach) end it.
+func drive(t *testing.T, m model, msg tea.Msg) model {
+	t.Helper()
+	for i := 0; i < 16 && msg != nil; i++ {
+		next, cmd := m.Update(msg)
+		m = next.(model)
+		if cmd == nil {
+			return m
+		}
+		msg = cmd()
+		switch msg.(type) {
+		case loadedMsg, actionMsg, createdMsg, viewCreatedMsg, softAttachMsg, previewMsg:
+		default:
+			return m
+		}
+	}
+	return m
+}
+
+func TestUpdateLoopWithFakeTmux(t *testing.T) {
+	groups := []discovery.WorkspaceGroup{
+		{Workspace: "root", Repo: "root", Name: "root", Path: "/"},
+		{Workspace: "git", Repo: "app", Name: "git/app", Path: "/git/app"},
+	}
+	tests := []struct {
+		name         string
+		sessions     []tmuxtest.Session
+		env          map[string]string
+		keys         []string
+		wantSessions []string
+		wantKeys     map[string][]string
+		wantStatus   string
+	}{
+		{
+			name:         "destroy selected session",
+			sessions:     []tmuxtest.Session{{Name: "app-shell-1", Path: "/git/app"}, {Name: "app-shell-2", Path: "/git/app"}},
+			keys:         []string{"down", "down", "d"},
+			wantSessions: []string{"app-shell-2"},
+		},
+		{
+			name:         "menu destroys selected session",
+			sessions:     []tmuxtest.Session{{Name: "app-shell-1", Path: "/git/app"}, {Name: "app-shell-2", Path: "/git/app"}},
+			keys:         []string{"down", "down", "0", "down", "enter"},
+			wantSessions: []string{"app-shell-2"},
+		},
+		{
+			name:         "template menu creates shell session in repo",
+			keys:         []string{"down", "ctrl+n", "enter"},
+			wantSessions: []string{"app-shell-1"},
+		},
+		{
+			name:         "spawn key types command into new session",
+			keys:         []string{"down", "c"},
+			wantSessions: []string{"app-claude-full-1"},
+			wantKeys:     map[string][]string{"app-claude-full-1:0.0": {"claude --dangerously-skip-permissions", "C-m"}},
+		},
+		{
+			name:         "new session takes next free number",
+			sessions:     []tmuxtest.Session{{Name: "app-shell-1", Path: "/git/app"}},
+			keys:         []string{"down", "b"},
+			wantSessions: []string{"app-shell-1", "app-shell-2"},
+		},
+		{
+			name:         "rename keeps repo prefix",
+			sessions:     []tmuxtest.Session{{Name: "app-shell-1", Path: "/git/app"}},
+			keys:         []string{"down", "down", "e", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "n", "o", "t", "e", "s", "enter"},
+			wantSessions: []string{"app-notes"},
+		},
+		{
+			name:         "failed rename reports tmux error",
+			sessions:     []tmuxtest.Session{{Name: "app-shell-1", Path: "/git/app"}, {Name: "app-x", Path: "/git/app"}},
+			keys:         []string{"down", "down", "e", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "x", "enter"},
+			wantSessions: []string{"app-shell-1", "app-x"},
+			wantStatus:   "Action failed: duplicate session: app-x",
+		},
+		{
+			name:         "restart reruns template parsed from name",
+			sessions:     []tmuxtest.Session{{Name: "app-lazygit-1", Path: "/git/app"}},
+			keys:         []string{"down", "down", "x"},
+			wantSessions: []string{"app-lazygit-1"},
+			wantKeys:     map[string][]string{"app-lazygit-1:0.0": {"lazygit", "C-m"}},
+		},
+		{
+			name:         "enter on empty repo creates default session",
+			sessions:     []tmuxtest.Session{{Name: "scratch", Path: "/tmp"}},
+			keys:         []string{"down", "enter"},
+			wantSessions: []string{"app-shell-1", "scratch"},
+		},
+		{
+			name:         "enter on empty repo jumps when configured",
+			sessions:     []tmuxtest.Session{{Name: "scratch", Path: "/tmp"}},
+			env:          map[string]string{"ECHOSHELL_EMPTY_ENTER": "jump"},
+			keys:         []string{"down", "enter"},
+			wantSessions: []string{"scratch"},
+			wantStatus:   "Attaching scratch...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tmuxtest.NewFake(tt.sessions...)
+			useFakeTmux(t, f, groups)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			m := model{multiSelected: map[string]bool{}, newTemplates: config.DefaultTemplates()}
+			m = drive(t, m, loadCmd()())
+			for _, k := range tt.keys {
+				m = drive(t, m, keyMsg(k))
+			}
+
+			if got := f.Names(); !reflect.DeepEqual(got, tt.wantSessions) {
+				t.Fatalf("sessions = %v, want %v", got, tt.wantSessions)
+			}
+			if tt.wantKeys != nil && !reflect.DeepEqual(f.Keys, tt.wantKeys) {
+				t.Fatalf("send-keys = %v, want %v", f.Keys, tt.wantKeys)
+			}
+			if tt.wantStatus != "" && m.status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", m.status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRepoOrderPinsAndHidesEmpty(t *testing.T) {
+	m := model{
+		groups: []discovery.WorkspaceGroup{
+			{Name: "root", Repo: "root"},
+			{Name: "git/app", Repo: "app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}}},
+			{Name: "git/docs", Repo: "docs"},
+			{Name: "git/tools", Repo: "tools"},
+		},
+		view: state.View{Pinned: []string{"git/tools"}, HideEmpty: true},
+	}
+	if got := m.repoOrder(); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Fatalf("expected pinned tools then app, got %v", got)
+	}
+}
+
+func TestCollapsedRepoSkipsSessionsAndPersists(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := model{
+		groups: []discovery.WorkspaceGroup{
+			{Name: "git/app", Repo: "app", Sessions: []discovery.SessionInfo{{Name: "app-shell-1"}, {Name: "app-shell-2"}}},
+			{Name: "git/web", Repo: "web", Sessions: []discovery.SessionInfo{{Name: "web-shell-1"}}},
+		},
+		selectedSession: 1,
+	}
+	m.toggleCollapsed()
+	if m.selectedSession != -1 {
+		t.Fatalf("expected repo row selected after collapsing, got %d", m.selectedSession)
+	}
+	m.shiftVertical(1)
+	if m.selectedWorkspace != 1 || m.selectedSession != -1 {
+		t.Fatalf("expected down to skip folded sessions, got %d/%d", m.selectedWorkspace, m.selectedSession)
+	}
+	if v, _ := state.LoadView(); !reflect.DeepEqual(v.Collapsed, []string{"git/app"}) {
+		t.Fatalf("expected collapsed repo saved, got %#v", v)
+	}
+}
+
+func TestListScrollsToKeepSelectionVisible(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := model{height: 14, width: 80}
+	for _, r := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		m.groups = append(m.groups, discovery.WorkspaceGroup{Name: "git/" + r, Repo: "repo-" + r})
+	}
+	for i := 0; i < 7; i++ {
+		next, _ := m.Update(keyMsg("down"))
+		m = next.(model)
+	}
+	if m.listOffset == 0 {
+		t.Fatalf("expected the list to scroll")
+	}
+	out := m.View()
+	if !strings.Contains(out, "repo-h") || strings.Contains(out, "repo-a") {
+		t.Fatalf("expected the window to follow the selection:\n%s", out)
+	}
+}
+
+func TestPageKeysMoveSelectionAndShowPosition(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := model{height: 14, width: 80}
+	for _, r := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		m.groups = append(m.groups, discovery.WorkspaceGroup{Name: "git/" + r, Repo: "repo-" + r})
+	}
+	step := func(k string) {
+		next, _ := m.Update(keyMsg(k))
+		m = next.(model)
+	}
+	step("pgdown")
+	if m.selectedWorkspace != m.pageSize() {
+		t.Fatalf("expected pgdown to move a page, got %d", m.selectedWorkspace)
+	}
+	step("end")
+	if m.selectedWorkspace != 7 {
+		t.Fatalf("expected end to select the last repo, got %d", m.selectedWorkspace)
+	}
+	if out := m.View(); !strings.Contains(out, "of 15") {
+		t.Fatalf("expected a position indicator:\n%s", out)
+	}
+	step("home")
+	if m.selectedWorkspace != 0 || m.listOffset != 0 {
+		t.Fatalf("expected home to select and show the first repo, got %d at %d", m.selectedWorkspace, m.listOffset)
+	}
+}
+
+func TestMouseSelectsAttachesAndOpensMenu(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := model{height: 20, width: 80}
+	m.groups = []discovery.WorkspaceGroup{
+		{Name: "git/a", Repo: "repo-a", Sessions: []discovery.SessionInfo{{Name: "repo-a-shell-1"}}},
+		{Name: "git/b", Repo: "repo-b", Sessions: []discovery.SessionInfo{{Name: "repo-b-shell-1"}, {Name: "repo-b-shell-2"}}},
+	}
+	lineOf := func(text string) int {
+		for y, line := range strings.Split(m.View(), "\n") {
+			if strings.Contains(line, text) {
+				return y
+			}
+		}
+		t.Fatalf("%q not on screen", text)
+		return -1
+	}
+	click := func(button tea.MouseButton, y int) tea.Cmd {
+		next, cmd := m.Update(tea.MouseMsg{X: 5, Y: y, Button: button, Action: tea.MouseActionPress})
+		m = next.(model)
+		return cmd
+	}
+
+	y := lineOf("shell-2")
+	click(tea.MouseButtonLeft, y)
+	if m.selectedWorkspace != 1 || m.selectedSession != 1 {
+		t.Fatalf("expected click to select the row, got %d/%d", m.selectedWorkspace, m.selectedSession)
+	}
+	if cmd := click(tea.MouseButtonLeft, y); cmd == nil || m.status != "Attaching repo-b-shell-2..." {
+		t.Fatalf("expected double-click to attach, got %q", m.status)
+	}
+
+	click(tea.MouseButtonWheelUp, 0)
+	if m.selectedSession != 0 {
+		t.Fatalf("expected wheel to move the selection, got %d", m.selectedSession)
+	}
+
+	click(tea.MouseButtonRight, lineOf("shell-1"))
+	if !m.selectingMenu || m.selectedWorkspace != 0 || m.menuItems[0].Key != "attach" {
+		t.Fatalf("expected right-click to open the session menu, got %v %#v", m.selectingMenu, m.menuItems)
+	}
+}
+
+func TestKeymapDrivesMainViewAndHelp(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	keys := config.DefaultKeymap()
+	keys["down"] = []string{"s"}
+	m := model{keys: keys}
+	m.groups = []discovery.WorkspaceGroup{
+		{Name: "git/a", Repo: "repo-a", Sessions: []discovery.SessionInfo{{Name: "repo-a-shell-1"}, {Name: "repo-a-shell-2"}}},
+	}
+	step := func(k string) {
+		next, _ := m.Update(keyMsg(k))
+		m = next.(model)
+	}
+
+	step("j")
+	if m.selectedSession != 0 {
+		t.Fatalf("expected unbound j to do nothing, got %d", m.selectedSession)
+	}
+	step("s")
+	if m.selectedSession != 1 {
+		t.Fatalf("expected rebound key to move down, got %d", m.selectedSession)
+	}
+	if !strings.Contains(m.View(), "? keys") {
+		t.Fatalf("expected the help line to come from the keymap:\n%s", m.View())
+	}
+
+	step("?")
+	if out := m.View(); !m.showingHelp || !strings.Contains(out, "move down") || strings.Contains(out, "down / j") {
+		t.Fatalf("expected the key overlay:\n%s", m.View())
+	}
+	step("x")
+	if m.showingHelp {
+		t.Fatalf("expected any key to close the overlay")
+	}
+}
+
+func TestCommandPaletteFiltersAndRunsActions(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := model{newTemplates: config.DefaultTemplates()}
+	m.groups = []discovery.WorkspaceGroup{
+		{Name: "git/a", Repo: "repo-a", Sessions: []discovery.SessionInfo{{Name: "repo-a-shell-1"}}},
+	}
+	step := func(k string) {
+		next, _ := m.Update(keyMsg(k))
+		m = next.(model)
+	}
+
+	step("ctrl+p")
+	if !m.selectingPalette {
+		t.Fatalf("expected ctrl+p to open the palette")
+	}
+	for _, r := range "lazygt" {
+		step(string(r))
+	}
+	items := m.paletteMatches()
+	if len(items) == 0 || items[0].Action != "lazygit" || items[0].Keys != "l" {
+		t.Fatalf("expected a fuzzy match on lazygit with its key, got %#v", items)
+	}
+	if !strings.Contains(m.View(), "New session: Lazygit (lazygit)") {
+		t.Fatalf("expected template entries:\n%s", m.View())
+	}
+
+	step("esc")
+	step("ctrl+p")
+	for _, r := range "collapse" {
+		step(string(r))
+	}
+	step("enter")
+	if m.selectingPalette || !m.isCollapsed("git/a") {
+		t.Fatalf("expected the palette to run the fold toggle, got %v %v", m.selectingPalette, m.view.Collapsed)
+	}
+}
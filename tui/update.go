@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func updateCmd() tea.Cmd {
+	return func() tea.Msg {
+		if updateRepoDir == "" {
+			return actionMsg{err: errors.New("update unavailable (no local git repo)")}
+		}
+		if _, err := exec.LookPath("git"); err != nil {
+			return actionMsg{err: errors.New("git not found")}
+		}
+		if _, err := exec.LookPath("go"); err != nil {
+			return actionMsg{err: errors.New("go not found")}
+		}
+
+		dirty, err := runOutInDir(updateRepoDir, 8*time.Second, "git", "status", "--porcelain")
+		if err != nil {
+			return actionMsg{err: err}
+		}
+		if strings.TrimSpace(dirty) != "" {
+			return actionMsg{err: errors.New("working tree is dirty")}
+		}
+
+		if _, err := runOutInDir(updateRepoDir, 25*time.Second, "git", "pull", "--ff-only", "origin", "main"); err != nil {
+			return actionMsg{err: err}
+		}
+		if _, err := runOutInDir(updateRepoDir, 90*time.Second, "go", "build", "-o", "echoshell", "./cmd/echoshell"); err != nil {
+			return actionMsg{err: err}
+		}
+		return actionMsg{status: "Updated from origin/main. Restart echoshell."}
+	}
+}
+
+func detectRepoDir() string {
+	if env := strings.TrimSpace(os.Getenv("ECHOSHELL_REPO_DIR")); env != "" {
+		if root := findGitRoot(env); root != "" {
+			return root
+		}
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if root := findGitRoot(cwd); root != "" {
+			return root
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		exePath := exe
+		if resolved, rerr := filepath.EvalSymlinks(exe); rerr == nil {
+			exePath = resolved
+		}
+		if root := findGitRoot(filepath.Dir(exePath)); root != "" {
+			return root
+		}
+	}
+	return ""
+}
+
+func findGitRoot(dir string) string {
+	dir = filepath.Clean(strings.TrimSpace(dir))
+	if dir == "" {
+		return ""
+	}
+	for {
+		if hasGitDir(dir) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func hasGitDir(dir string) bool {
+	st, err := os.Stat(filepath.Join(dir, ".git"))
+	if err != nil {
+		return false
+	}
+	if st.IsDir() {
+		return true
+	}
+	return st.Mode().IsRegular()
+}
+
+func runOutInDir(dir string, timeout time.Duration, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out", name)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("%s", msg)
+	}
+	return stdout.String(), nil
+}